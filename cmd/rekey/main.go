@@ -0,0 +1,75 @@
+// Command rekey re-encrypts the content of snippets and their revisions, and
+// users' TOTP secrets, with the primary encryption key. Run it after adding a new primary key to the
+// front of the key list, or after turning encryption on for an existing
+// database, and remove the old key only once it has finished.
+//
+// Rows are updated in batches, each in its own transaction, so the web
+// application can keep running while it works and it can safely be stopped
+// and started again.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func main() {
+	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	encryptionKeys := flag.String("encryption-keys", os.Getenv("SNIPPETBOX_ENCRYPTION_KEYS"),
+		"Comma separated id:base64key pairs; the first is the primary key")
+	batchSize := flag.Int("batch-size", 100, "Number of rows to re-encrypt in each transaction")
+	pause := flag.Duration("pause", 100*time.Millisecond, "Time to wait between batches")
+	flag.Parse()
+
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	if *encryptionKeys == "" {
+		errorLog.Fatal("no encryption keys given")
+	}
+
+	keys, err := keyring.Parse(*encryptionKeys)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	db, err := sql.Open("mysql", *dsn)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	defer db.Close()
+
+	if err = db.Ping(); err != nil {
+		errorLog.Fatal(err)
+	}
+
+	snippets := &models.SnippetModel{DB: db, Keys: keys}
+	twoFactor := &models.TwoFactorModel{DB: db, Keys: keys}
+
+	total := 0
+	for _, rekey := range []func(int) (int, error){snippets.Rekey, twoFactor.Rekey} {
+		for {
+			n, err := rekey(*batchSize)
+			if err != nil {
+				errorLog.Fatal(err)
+			}
+			if n == 0 {
+				break
+			}
+
+			total += n
+			infoLog.Printf("re-encrypted %d rows", total)
+			time.Sleep(*pause)
+		}
+	}
+
+	infoLog.Printf("done: all content is encrypted with key %q", keys.Primary())
+}
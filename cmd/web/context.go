@@ -3,3 +3,7 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// isActivatedContextKey is set alongside isAuthenticatedContextKey once the
+// logged in user has verified their email address.
+const isActivatedContextKey = contextKey("isActivated")
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// featuredCacheTTL is how long the featured snippets are cached for before
+// being reloaded, so that pinned snippets which expire drop off the home page
+// even if nobody changes the pins.
+const featuredCacheTTL = 5 * time.Minute
+
+// featuredCache keeps the featured snippets in memory so that showing them on
+// the home page doesn't cost a query per view. It must be invalidated whenever
+// the pins change.
+type featuredCache struct {
+	model models.FeaturedModelInterface
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	snippets []*models.Snippet
+	expires  time.Time
+}
+
+func newFeaturedCache(model models.FeaturedModelInterface, ttl time.Duration) *featuredCache {
+	return &featuredCache{model: model, ttl: ttl}
+}
+
+// Get returns the featured snippets, loading them from the model if the cache
+// is empty or stale. The returned slice must not be modified.
+func (c *featuredCache) Get() ([]*models.Snippet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.snippets != nil && time.Now().Before(c.expires) {
+		return c.snippets, nil
+	}
+
+	snippets, err := c.model.All()
+	if err != nil {
+		return nil, err
+	}
+
+	c.snippets = snippets
+	c.expires = time.Now().Add(c.ttl)
+	return snippets, nil
+}
+
+// Invalidate empties the cache, so that the next call to Get reloads it.
+func (c *featuredCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snippets = nil
+}
@@ -4,13 +4,47 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/gosource"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/langdetect"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/qrcode"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/sealed"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/secrets"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/spdx"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/totp"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
 
+// goSourceTimeout bounds how long formatting or parse-checking a Go snippet
+// may take.
+const goSourceTimeout = 2 * time.Second
+
+// anonymousMaxExpires is the longest, in days, that a guest's snippet can be
+// kept for.
+const anonymousMaxExpires = 7
+
+// passwordResetTTL is how long a password reset link can be used for, and
+// passwordResetLimit is how many resets can be requested per hour, both from
+// one IP address and for one email address.
+const (
+	passwordResetTTL   = time.Hour
+	passwordResetLimit = 5
+)
+
+// activationTTL is how long an email verification link can be used for, and
+// activationResendLimit is how many verification emails a user can ask for
+// per hour.
+const (
+	activationTTL         = 3 * 24 * time.Hour
+	activationResendLimit = 3
+)
+
 // This struct represents form data and errors. All fields are exported so they
 // can be read by the HTML template.
 
@@ -23,7 +57,114 @@ type snippetCreateForm struct {
 	Title               string     `form:"title"`
 	Content             string     `form:"content"`
 	Expires             int        `form:"expires"`
+	Language            string     `form:"language"`
+	PublishAt           string     `form:"publish_at"`
+	Timezone            string     `form:"timezone"`
+	AllowDuplicate      bool       `form:"allow_duplicate"`
+	SecretAction        string     `form:"secret_action"`
+	ForkedFrom          int        `form:"forked_from"`
+	License             string     `form:"license"`
+	SourceURL           string     `form:"source_url"`
+	Attribution         string     `form:"attribution"`
 	validator.Validator `form:"-"` // Embed a validator
+	// Secrets holds the suspected secrets found by the secret scanner. When it
+	// is non-empty the template warns the user and asks whether to redact the
+	// matches or publish anyway.
+	Secrets []secrets.Finding `form:"-"`
+	// Duplicate holds the user's existing live snippet with the same content,
+	// if one was found. The template uses it to offer reusing or linking to
+	// that snippet instead of creating a copy.
+	Duplicate *models.Snippet `form:"-"`
+}
+
+// secretCreateForm holds a snippet encrypted in the browser. The plaintext
+// and the key are never sent to the server.
+type secretCreateForm struct {
+	Payload             string `form:"payload"`
+	Expires             int    `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+type snippetDeleteForm struct {
+	ID    int    `form:"-"`
+	Token string `form:"token"`
+}
+
+type snippetLanguageForm struct {
+	Language            string `form:"language"`
+	validator.Validator `form:"-"`
+}
+
+type collectionForm struct {
+	Name                string `form:"name"`
+	Slug                string `form:"slug"`
+	Description         string `form:"description"`
+	validator.Validator `form:"-"`
+}
+
+type collectionSnippetForm struct {
+	SnippetID           int    `form:"snippet_id"`
+	Direction           string `form:"direction"`
+	validator.Validator `form:"-"`
+}
+
+type featuredForm struct {
+	SnippetID           int    `form:"snippet_id"`
+	Direction           string `form:"direction"`
+	validator.Validator `form:"-"`
+}
+
+type quotaForm struct {
+	Email               string `form:"email"`
+	Snippets            int    `form:"snippets"`
+	Bytes               int    `form:"bytes"`
+	PerHour             int    `form:"per_hour"`
+	UserID              int    `form:"user_id"`
+	validator.Validator `form:"-"`
+}
+
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"currentPassword"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+type userPasswordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type userPasswordResetForm struct {
+	Token                   string `form:"token"`
+	NewPassword             string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator     `form:"-"`
+}
+
+type userActivateForm struct {
+	Token               string `form:"token"`
+	validator.Validator `form:"-"`
+}
+
+type twoFactorEnableForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+type twoFactorDisableForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type sessionRevokeForm struct {
+	ID                  int `form:"id"`
+	validator.Validator `form:"-"`
+}
+
+type userLoginTwoFactorForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
 }
 
 type userSignupForm struct {
@@ -36,6 +177,7 @@ type userSignupForm struct {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Remember            bool   `form:"remember"`
 	validator.Validator `form:"-"`
 }
 
@@ -62,8 +204,17 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The featured snippets come from the in-memory cache, so they don't add
+	// a query to every view of the home page.
+	featured, err := app.featuredCache.Get()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Featured = featured
 
 	app.render(w, http.StatusOK, "home.html", data)
 }
@@ -103,9 +254,73 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Scheduled snippets which haven't been published yet are only visible to
+	// their owner. Everyone else gets the same response as for a snippet that
+	// doesn't exist.
+	if !app.canView(r, snippet) {
+		app.notFound(w)
+		return
+	}
+
+	// Secret snippets have their own page. The browser keeps the key in the
+	// URL fragment across the redirect.
+	if snippet.IsSecret() {
+		http.Redirect(w, r, fmt.Sprintf("/secret/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	// An optional "lines" query string parameter, such as "?lines=10-20",
+	// highlights a range of lines both on the page and in the excerpt used for
+	// link previews. An invalid range is ignored.
+	highlight, _ := parseLineRange(r.URL.Query().Get("lines"))
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
 
+	// Views of published snippets count towards the trending score, except
+	// when the owner is looking at their own snippet. Viewers are told apart
+	// by user ID, or by IP address if they aren't logged in, and only the
+	// hash is stored. Failing to record a view isn't worth failing the
+	// request for.
+	if snippet.Published && snippet.UserID != data.AuthenticatedUserID {
+		viewer := "ip:" + clientIP(r)
+		if data.IsAuthenticated {
+			viewer = fmt.Sprintf("user:%d", data.AuthenticatedUserID)
+		}
+
+		err = app.trending.RecordView(snippet.ID, hashToken(viewer))
+		if err != nil {
+			app.errorLog.Printf("recording view of snippet %d: %s", snippet.ID, err)
+		}
+	}
+
+	if data.IsAuthenticated {
+		data.Starred, err = app.trending.Starred(data.AuthenticatedUserID, snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	data.Related, err = app.relatedSnippets(snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.Lines = snippetLines(snippet.Content, highlight)
+	data.Excerpt = excerpt(data.Lines)
+
+	// Go snippets are parse-checked so that any syntax errors can be shown
+	// alongside the content. A snippet which is too large or takes too long
+	// to check is shown without them.
+	if snippet.Language == "go" {
+		data.SyntaxErrors, err = gosource.Check(snippet.Content, goSourceTimeout)
+		if err != nil {
+			app.infoLog.Printf("skipping syntax check of snippet %d: %s", snippet.ID, err)
+		}
+	}
+
 	app.render(w, http.StatusOK, "view.html", data)
 
 }
@@ -124,10 +339,36 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// Notice how this is also a great opportunity to set any default or
 	// 'initial' values for the form --- here we set the initial value for the
 	// snippet expiry to 365 days.
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	form := snippetCreateForm{
+		Expires:  365,
+		Timezone: "UTC",
+	}
+	if !app.isAuthenticated(r) {
+		form.Expires = anonymousMaxExpires
+	}
+
+	// A "fork" query string parameter starts the new snippet as a copy of an
+	// existing one, which is recorded as its origin when it is created.
+	if fork, err := strconv.Atoi(r.URL.Query().Get("fork")); err == nil && fork > 0 {
+		original, err := app.snippets.Get(fork)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		if original != nil && app.canView(r, original) && !original.IsSecret() {
+			form.Title = original.Title
+			form.Content = original.Content
+			form.Language = original.Language
+			form.ForkedFrom = original.ID
+			form.License = original.License
+			form.SourceURL = original.SourceURL
+			form.Attribution = original.Attribution
+		}
 	}
 
+	data.Form = form
+
 	app.render(w, http.StatusOK, "create.html", data)
 }
 
@@ -156,6 +397,59 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
 	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
 	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.PermittedValue(form.Language, append(langdetect.IDs(), "")...), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.PermittedValue(form.SecretAction, "", "redact", "publish"), "content", "Invalid secret scanning action")
+	form.CheckField(validator.MaxChars(form.SourceURL, 2048), "source_url", "This field cannot be more than 2048 characters long")
+	form.CheckField(form.SourceURL == "" || validator.WebURL(form.SourceURL), "source_url", "This field must be an http or https URL")
+	form.CheckField(validator.MaxChars(form.Attribution, 255), "attribution", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.SingleLine(form.Attribution), "attribution", "This field must be a single line")
+
+	// The license is optional, but if given it must be a known SPDX
+	// identifier. It is stored with its canonical spelling.
+	if form.License != "" {
+		license, ok := spdx.Lookup(form.License)
+		if ok {
+			form.License = license.ID
+		} else {
+			form.AddFieldError("license", "This field must be an SPDX license identifier, such as MIT")
+		}
+	}
+
+	// In anonymous mode guests can create snippets too, but only short-lived
+	// ones which are published straight away.
+	guest := !app.isAuthenticated(r)
+	if guest {
+		form.CheckField(form.Expires <= anonymousMaxExpires, "expires", "Log in to keep snippets for longer than a week")
+		form.CheckField(form.PublishAt == "", "publish_at", "Log in to schedule snippets")
+	}
+
+	// The optional publish time comes from a datetime-local input, which has
+	// no timezone of its own, so it is interpreted in the timezone sent along
+	// with it and then converted to UTC for storage.
+	var publishAt time.Time
+	if form.PublishAt != "" {
+		loc, err := time.LoadLocation(form.Timezone)
+		if err != nil {
+			form.AddFieldError("publish_at", "Unknown timezone")
+		} else {
+			publishAt, err = time.ParseInLocation("2006-01-02T15:04", form.PublishAt, loc)
+			if err != nil {
+				form.AddFieldError("publish_at", "This field must be a valid date and time")
+			} else {
+				form.CheckField(publishAt.After(time.Now()), "publish_at", "This field must be in the future")
+			}
+		}
+	}
+
+	// Guests are rate limited by IP address instead of having a quota.
+	var quota *models.Quota
+	if !guest {
+		quota, err = app.checkQuota(&form.Validator, "content", app.authenticatedUserID(r), len(form.Content))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
 
 	// Use the Valid() method to see if any of the checks failed. If they did,
 	// then re-render the template passing in the form in the same way as
@@ -167,147 +461,1901 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// Scan the content for credentials before it is published. If anything
+	// is found and the user hasn't yet decided what to do about it, re-display
+	// the form with a warning. Choosing "redact" replaces the matches with
+	// placeholders; choosing "publish" keeps the content as it is.
+	findings := app.secretScanner.Scan(form.Content)
+	if len(findings) > 0 {
+		switch form.SecretAction {
+		case "redact":
+			form.Content = secrets.Redact(form.Content, findings)
+		case "":
+			form.Secrets = findings
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+			return
+		}
+	}
+
+	userID := app.authenticatedUserID(r)
 
-	if err != nil {
+	// Unless the check is disabled, or the user has already been shown the
+	// duplicate and chosen to create a copy anyway, look for one of their own
+	// live snippets with the same normalised content. Guests all share a user
+	// ID of 0, so they are never checked.
+	if app.config.dedupe && !form.AllowDuplicate && !guest {
+		duplicate, err := app.snippets.FindDuplicate(userID, models.ContentHash(form.Content))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		if duplicate != nil {
+			form.Duplicate = duplicate
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+			return
+		}
+	}
+
+	// Only record the snippet as a fork if the original is still one the user
+	// can see; otherwise it is created as an ordinary snippet.
+	if form.ForkedFrom > 0 {
+		original, err := app.snippets.Get(form.ForkedFrom)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		if original == nil || !app.canView(r, original) || original.IsSecret() {
+			form.ForkedFrom = 0
+		}
+	}
+
+	snippet := &models.Snippet{
+		UserID:      userID,
+		Title:       form.Title,
+		Content:     form.Content,
+		Language:    form.Language,
+		PublishAt:   publishAt.UTC(),
+		ForkedFrom:  form.ForkedFrom,
+		License:     form.License,
+		SourceURL:   form.SourceURL,
+		Attribution: form.Attribution,
+	}
+
+	// A guest can't own the snippet, so they get a secret token which lets
+	// them delete it instead. Only its hash is stored.
+	var deleteToken string
+	if guest {
+		deleteToken, snippet.DeleteTokenHash, err = newToken()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// If the user didn't pick a language, try to infer one from the content.
+	// The confidence score is stored too, so that the view page can show the
+	// language as detected and offer to change it.
+	if snippet.Language == "" {
+		snippet.Language, snippet.LanguageConfidence = langdetect.Detect(snippet.Content)
+	}
+
+	id, err := app.snippets.Insert(snippet, form.Expires, quota)
+	if errors.Is(err, models.ErrOverQuota) {
+		form.AddNonFieldError(overQuotaMessage)
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	} else if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	snippet.ID = id
+	app.indexSnippet(snippet)
+
+	// The delete link is shown to the guest once, on this response, rather
+	// than redirecting with it in a flash message. That would leave the token
+	// in the session store.
+	if guest {
+		w.Header().Set("Cache-Control", "no-store")
+
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.DeleteURL = fmt.Sprintf("%s/snippet/delete/%d?token=%s", app.config.baseURL, id, deleteToken)
+		app.render(w, http.StatusCreated, "created.html", data)
+		return
+	}
+
 	// Use the Put() method to add a string value ("Snippet successfully
 	// created!") and the corresponding key ("flash") to the session data.
-	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	if snippet.PublishAt.IsZero() {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet successfully scheduled! Only you can see it until it is published.")
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 
 }
 
-func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+// secretCreate shows the form for a secret snippet, which is encrypted by
+// secret.js before it is sent.
+func (app *application) secretCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
-	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.html", data)
+	data.Form = secretCreateForm{
+		Expires: 7,
+	}
+
+	app.render(w, http.StatusOK, "secret_create.html", data)
 }
 
-func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
-	// Declare an zero-valued instance of our userSignupForm struct.
-	var form userSignupForm
+// secretCreatePost stores a secret snippet. The payload is checked to be
+// well-formed ciphertext, so that plain text is never stored as a secret by
+// mistake, but it can't be decrypted here. None of the processing done for
+// ordinary snippets, such as secret scanning or language detection, applies.
+func (app *application) secretCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form secretCreateForm
 
-	// Parse the form data into the userSignupForm struct.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	// Perform form data validation
-	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
-	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+
+	switch err := sealed.Validate(form.Payload); {
+	case errors.Is(err, sealed.ErrTooLarge):
+		form.AddFieldError("payload", "This snippet is too large")
+	case err != nil:
+		form.AddFieldError("payload", "The snippet wasn't encrypted. Check that JavaScript is enabled and try again")
+	}
+
+	quota, err := app.checkQuota(&form.Validator, "payload", app.authenticatedUserID(r), len(form.Payload))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	// If there are any errors, redisplay the signup form along with a 422
-	// status code.
 	if !form.Valid() {
 		data := app.newTemplateData(r)
+		form.Payload = ""
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+		app.render(w, http.StatusUnprocessableEntity, "secret_create.html", data)
 		return
 	}
 
-	// Try to create a new user record in the database. If the email already
-	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
-	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			form.AddFieldError("email", "Email address is already in use")
+	snippet := &models.Snippet{
+		UserID:  app.authenticatedUserID(r),
+		Kind:    models.KindSecret,
+		Title:   "Encrypted snippet",
+		Content: form.Payload,
+	}
 
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
-		} else {
-			app.serverError(w, err)
-		}
+	id, err := app.snippets.Insert(snippet, form.Expires, quota)
+	if errors.Is(err, models.ErrOverQuota) {
+		form.AddNonFieldError(overQuotaMessage)
+		data := app.newTemplateData(r)
+		form.Payload = ""
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "secret_create.html", data)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
 		return
 	}
 
-	// Otherwise add a confirmation flash message to the session confirming that
-	// their signup worked.
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Secret snippet created! Anyone with the full link can read it, so share it carefully.")
 
-	// And redirect the user to the login page.
-	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	// The form was posted to a URL with the key in its fragment. The Location
+	// has no fragment of its own, so the browser carries the key over.
+	http.Redirect(w, r, fmt.Sprintf("/secret/view/%d", id), http.StatusSeeOther)
 }
 
-func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+// secretView shows a secret snippet. The page only holds the ciphertext;
+// secret.js decrypts it with the key from the URL fragment.
+func (app *application) secretView(w http.ResponseWriter, r *http.Request) {
+	snippet := app.viewableSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	if !snippet.IsSecret() {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.html", data)
+	data.Snippet = snippet
+
+	app.render(w, http.StatusOK, "secret_view.html", data)
 }
 
-func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
-	var form userLoginForm
+// snippetDelete asks a guest to confirm deleting a snippet, using the delete
+// link they were given when they created it.
+func (app *application) snippetDelete(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
 
-	err := app.decodePostForm(r, &form)
-	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
-	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	w.Header().Set("Cache-Control", "no-store")
 
-	if !form.Valid() {
-		data := app.newTemplateData(r)
-		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+	data := app.newTemplateData(r)
+	data.Form = snippetDeleteForm{ID: id, Token: r.URL.Query().Get("token")}
+	app.render(w, http.StatusOK, "delete.html", data)
+}
+
+// snippetDeletePost deletes a snippet if the token matches its delete token.
+// A wrong token gets the same response as a snippet which doesn't exist.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	var form snippetDeleteForm
+
+	err = app.decodePostForm(r, &form)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
-			form.AddNonFieldError("Email or password is incorrect")
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
 
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+	err = app.snippets.DeleteWithToken(id, hashToken(form.Token))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
 		} else {
 			app.serverError(w, err)
 		}
-
 		return
 	}
 
-	// Use the RenewToken() method on the current session to change the session
-	// ID. It's good practice to generate a new session ID when the
-	// authentication state or privilege levels changes for the user (e.g. login
-	// and logout operations).
-	err = app.sessionManager.RenewToken(r.Context())
-	if err != nil {
-		app.serverError(w, err)
-		return
-	}
+	app.related.Remove(id)
 
-	// Add the ID of the current user to the session, so that they are now
-	// 'logged in'.
-	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
 
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	// Use the RenewToken() method on the current session to change the session
-	// ID again.
-	err := app.sessionManager.RenewToken(r.Context())
+// snippetLanguagePost lets the owner of a snippet change its language, for
+// example to correct one which was detected wrongly.
+func (app *application) snippetLanguagePost(w http.ResponseWriter, r *http.Request) {
+	var form snippetLanguageForm
+
+	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.serverError(w, err)
+		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	// Remove the authenticatedUserID from the session data so that the user
-	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+	form.CheckField(validator.PermittedValue(form.Language, langdetect.IDs()...), "language", "This field must be one of the listed languages")
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet := app.ownedSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	err = app.snippets.SetLanguage(snippet.ID, form.Language)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippet.Language = form.Language
+	app.indexSnippet(snippet)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet language updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetFormatPost runs a Go snippet through go/format and saves the result
+// as a new revision of the snippet. Only the owner can format a snippet.
+func (app *application) snippetFormatPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.ownedSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	if snippet.Language != "go" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	formatted, err := gosource.Format(snippet.Content, goSourceTimeout)
+	switch {
+	case err != nil:
+		app.sessionManager.Put(r.Context(), "flash", "The snippet could not be formatted: "+err.Error())
+	case formatted == snippet.Content:
+		app.sessionManager.Put(r.Context(), "flash", "The snippet is already formatted.")
+	default:
+		quota, err := app.quotaFor(snippet.UserID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.snippets.Revise(snippet.ID, formatted, &quota)
+		if errors.Is(err, models.ErrOverQuota) {
+			app.sessionManager.Put(r.Context(), "flash", "The formatted snippet would take you over your storage limit.")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		} else {
+			snippet.Content = formatted
+			app.indexSnippet(snippet)
+			app.sessionManager.Put(r.Context(), "flash", "Snippet formatted and saved as a new revision!")
+		}
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetRaw serves the content of a snippet as plain text, headed by its
+// license and attribution.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet := app.viewableSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(snippetFile(snippet)))
+}
+
+// snippetDownload serves the same content as snippetRaw, as a file named for
+// the snippet's language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet := app.viewableSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snippetFilename(snippet)))
+	w.Write([]byte(snippetFile(snippet)))
+}
+
+// snippetStarPost stars a snippet for the logged in user.
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.viewableSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	err := app.trending.Star(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.viewableSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	err := app.trending.Unstar(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// trendingList shows the highest scoring snippets, as ranked by the last run
+// of the background rescoring job.
+func (app *application) trendingList(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.trending.Top(trendingSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "trending.html", data)
+}
+
+// collectionList shows the logged in user's collections.
+func (app *application) collectionList(w http.ResponseWriter, r *http.Request) {
+	collections, err := app.collections.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collections = collections
+
+	app.render(w, http.StatusOK, "collections.html", data)
+}
+
+func (app *application) collectionCreate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = collectionForm{}
+	app.render(w, http.StatusOK, "collection_create.html", data)
+}
+
+func (app *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Slug), "slug", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Slug, 100), "slug", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.Matches(form.Slug, validator.SlugRX), "slug", "This field may only contain lowercase letters, digits and single hyphens")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "collection_create.html", data)
+		return
+	}
+
+	id, err := app.collections.Insert(app.authenticatedUserID(r), form.Name, form.Slug, form.Description)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSlug) {
+			form.AddFieldError("slug", "This slug is already in use")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "collection_create.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", id), http.StatusSeeOther)
+}
+
+// collectionEdit shows the page for managing one of the user's collections:
+// renaming it, adding, removing and reordering its snippets, and deleting it.
+func (app *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	snippets, err := app.collections.EditableSnippets(collection.ID, collection.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = snippets
+	data.Form = collectionForm{
+		Name:        collection.Name,
+		Slug:        collection.Slug,
+		Description: collection.Description,
+	}
+
+	app.render(w, http.StatusOK, "collection_edit.html", data)
+}
+
+func (app *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The slug can't be changed once a collection has been created, so that
+	// existing links keep working.
+	form.Slug = collection.Slug
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		snippets, err := app.collections.EditableSnippets(collection.ID, collection.UserID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Collection = collection
+		data.Snippets = snippets
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "collection_edit.html", data)
+		return
+	}
+
+	err = app.collections.Update(collection.ID, form.Name, form.Description)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+func (app *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	err := app.collections.Delete(collection.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection deleted.")
+
+	http.Redirect(w, r, "/collections", http.StatusSeeOther)
+}
+
+// collectionAddPost adds a snippet to the end of a collection. Any live
+// snippet can be added, whoever its author is.
+func (app *application) collectionAddPost(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.SnippetID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := app.snippets.Get(form.SnippetID)
+	if err == nil && !app.canView(r, snippet) {
+		err = models.ErrNoRecord
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("There is no snippet #%d.", form.SnippetID))
+			http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Secret snippets can't be read without their key, so there's no point
+	// in listing them in a public collection.
+	if snippet.IsSecret() {
+		app.sessionManager.Put(r.Context(), "flash", "Encrypted snippets can't be added to collections.")
+		http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+		return
+	}
+
+	err = app.collections.AddSnippet(collection.ID, form.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSnippet) {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet #%d is already in this collection.", form.SnippetID))
+			http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet #%d added to the collection!", form.SnippetID))
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+func (app *application) collectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.SnippetID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.RemoveSnippet(collection.ID, form.SnippetID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+// collectionMovePost moves a snippet one place up or down in a collection.
+func (app *application) collectionMovePost(w http.ResponseWriter, r *http.Request) {
+	collection := app.ownedCollection(w, r)
+	if collection == nil {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.SnippetID < 1 || !validator.PermittedValue(form.Direction, "up", "down") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.collections.MoveSnippet(collection.ID, form.SnippetID, form.Direction == "up")
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+// collectionView shows the public page for a collection, found by its slug.
+func (app *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	collection, err := app.collections.GetBySlug(params.ByName("slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	snippets, err := app.collections.Snippets(collection.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "collection.html", data)
+}
+
+// adminFeatured shows the featured snippets with controls for reordering and
+// unpinning them, and a form for pinning another snippet.
+func (app *application) adminFeatured(w http.ResponseWriter, r *http.Request) {
+	app.renderAdminFeatured(w, r, http.StatusOK, featuredForm{})
+}
+
+func (app *application) renderAdminFeatured(w http.ResponseWriter, r *http.Request, status int, form featuredForm) {
+	// Read the pins from the model rather than the cache, so that admins
+	// always see the current state.
+	featured, err := app.featured.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Featured = featured
+	data.Form = form
+	data.MaxFeatured = app.config.maxFeatured
+
+	app.render(w, status, "admin_featured.html", data)
+}
+
+// adminFeaturedPinPost pins a published snippet to the end of the featured
+// list, as long as fewer than maxFeatured snippets are pinned already.
+func (app *application) adminFeaturedPinPost(w http.ResponseWriter, r *http.Request) {
+	var form featuredForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(form.SnippetID > 0, "snippet_id", "Enter the ID of a snippet")
+
+	if form.Valid() {
+		snippet, err := app.snippets.Get(form.SnippetID)
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			form.AddFieldError("snippet_id", "No live snippet has this ID")
+		case err != nil:
+			app.serverError(w, err)
+			return
+		case !snippet.Published:
+			form.AddFieldError("snippet_id", "This snippet hasn't been published yet")
+		case snippet.IsSecret():
+			form.AddFieldError("snippet_id", "Encrypted snippets can't be featured")
+		}
+	}
+
+	if form.Valid() {
+		count, err := app.featured.Count()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		form.CheckField(count < app.config.maxFeatured, "snippet_id",
+			fmt.Sprintf("No more than %d snippets can be featured; unpin one first", app.config.maxFeatured))
+	}
+
+	if form.Valid() {
+		err = app.featured.Pin(form.SnippetID)
+		if errors.Is(err, models.ErrDuplicateSnippet) {
+			form.AddFieldError("snippet_id", "This snippet is already featured")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.renderAdminFeatured(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	app.featuredCache.Invalidate()
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet featured!")
+
+	http.Redirect(w, r, "/admin/featured", http.StatusSeeOther)
+}
+
+func (app *application) adminFeaturedUnpinPost(w http.ResponseWriter, r *http.Request) {
+	var form featuredForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.SnippetID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.featured.Unpin(form.SnippetID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.featuredCache.Invalidate()
+
+	http.Redirect(w, r, "/admin/featured", http.StatusSeeOther)
+}
+
+// adminFeaturedMovePost moves a featured snippet one place up or down.
+func (app *application) adminFeaturedMovePost(w http.ResponseWriter, r *http.Request) {
+	var form featuredForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.SnippetID < 1 || !validator.PermittedValue(form.Direction, "up", "down") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.featured.Move(form.SnippetID, form.Direction == "up")
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	app.featuredCache.Invalidate()
+
+	http.Redirect(w, r, "/admin/featured", http.StatusSeeOther)
+}
+
+// adminQuotas lists the users whose quotas have been overridden, with a form
+// for setting another user's quota.
+func (app *application) adminQuotas(w http.ResponseWriter, r *http.Request) {
+	app.renderAdminQuotas(w, r, http.StatusOK, quotaForm{
+		Snippets: app.config.quota.Snippets,
+		Bytes:    app.config.quota.Bytes,
+		PerHour:  app.config.quota.PerHour,
+	})
+}
+
+func (app *application) renderAdminQuotas(w http.ResponseWriter, r *http.Request, status int, form quotaForm) {
+	overrides, err := app.quotas.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.QuotaOverrides = overrides
+	data.Quota = app.config.quota
+	data.Form = form
+
+	app.render(w, status, "admin_quotas.html", data)
+}
+
+// adminQuotasSetPost overrides the default limits for the user with the
+// given email address.
+func (app *application) adminQuotasSetPost(w http.ResponseWriter, r *http.Request) {
+	var form quotaForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(form.Snippets >= models.NoLimit, "snippets", "This field must be -1 or more")
+	form.CheckField(form.Bytes >= models.NoLimit, "bytes", "This field must be -1 or more")
+	form.CheckField(form.PerHour >= models.NoLimit, "per_hour", "This field must be -1 or more")
+
+	if form.Valid() {
+		err = app.quotas.Set(form.Email, models.Quota{
+			Snippets: form.Snippets,
+			Bytes:    form.Bytes,
+			PerHour:  form.PerHour,
+		})
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddFieldError("email", "No user has this email address")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		app.renderAdminQuotas(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Quota updated!")
+
+	http.Redirect(w, r, "/admin/quotas", http.StatusSeeOther)
+}
+
+// adminQuotasRemovePost removes a user's quota override, so that the default
+// limits apply to them again.
+func (app *application) adminQuotasRemovePost(w http.ResponseWriter, r *http.Request) {
+	var form quotaForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.UserID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.quotas.Remove(form.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Quota reset to the defaults.")
+
+	http.Redirect(w, r, "/admin/quotas", http.StatusSeeOther)
+}
+
+// accountView shows the user's profile, a summary of their snippets and
+// sessions, and how much of their quota they have used.
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	counts, err := app.snippets.Counts(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	activeSessions, err := app.sessions.Count(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	quota, err := app.quotaFor(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	usage, err := app.quotas.Usage(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	twoFactorEnabled, err := app.twoFactor.Enabled(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	var recoveryCodesLeft int
+	if twoFactorEnabled {
+		recoveryCodesLeft, err = app.twoFactor.RecoveryCodesLeft(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.SnippetCounts = counts
+	data.TwoFactorEnabled = twoFactorEnabled
+	data.RecoveryCodesLeft = recoveryCodesLeft
+	data.ActiveSessions = activeSessions
+	data.Quota = quota
+	data.Usage = usage
+
+	app.render(w, http.StatusOK, "account.html", data)
+}
+
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+	app.render(w, http.StatusOK, "password.html", data)
+}
+
+// accountPasswordUpdatePost changes the user's password. Because whoever
+// knew the old password might still be logged in somewhere, the current
+// session gets a new token and every other session is logged out.
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	err = app.users.PasswordUpdate(userID, form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "password.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	token := app.sessionManager.Token(r.Context())
+
+	err = app.sessions.RevokeAll(userID, token)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Devices which were remembered are forgotten too, including this one,
+	// since a remember me cookie is as good as the old password.
+	err = app.rememberTokens.DeleteAllForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	clearRememberCookie(w)
+
+	err = app.sessions.Add(token, userID, r.UserAgent(), clientIP(r), "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated! You have been logged out everywhere else.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// accountTwoFactorEnable shows the QR code and secret for the user to add to
+// their authenticator app. The secret is kept, still pending, until they
+// enter a code from it, so reloading the page shows the same one.
+func (app *application) accountTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	app.renderTwoFactorEnable(w, r, http.StatusOK, twoFactorEnableForm{})
+}
+
+func (app *application) renderTwoFactorEnable(w http.ResponseWriter, r *http.Request, status int, form twoFactorEnableForm) {
+	if !app.config.twoFactor {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	enabled, err := app.twoFactor.Enabled(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if enabled {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	secret, err := app.twoFactor.Secret(userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		secret, err = totp.NewSecret()
+		if err == nil {
+			err = app.twoFactor.SetSecret(userID, secret)
+		}
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// A very long email address can make the URI too long for a QR code, in
+	// which case the user has to type the secret in instead.
+	qr, err := totpQRCode(user.Email, secret)
+	if err != nil && !errors.Is(err, qrcode.ErrTooLong) {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.TOTPSecret = formatTOTPSecret(secret)
+	data.QRCode = qr
+	app.render(w, status, "twofactor_enable.html", data)
+}
+
+// accountTwoFactorEnablePost turns on two-factor authentication once the user
+// has entered a code from their authenticator app, proving that it has the
+// secret, and shows their recovery codes. The codes are only shown this once.
+func (app *application) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	if !app.config.twoFactor {
+		app.notFound(w)
+		return
+	}
+
+	var form twoFactorEnableForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	secret, err := app.twoFactor.Secret(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/2fa/enable", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	step, ok := totp.Validate(secret, form.Code, time.Now(), 1)
+	if form.Valid() && !ok {
+		form.AddFieldError("code", "This code is incorrect. Check that the time on your device is right")
+	}
+
+	if !form.Valid() {
+		app.renderTwoFactorEnable(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.twoFactor.Enable(userID, step, hashes)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Recovery codes are as good as a password, so keep the page out of
+	// caches and the browser's history.
+	w.Header().Set("Cache-Control", "no-store")
+
+	data := app.newTemplateData(r)
+	data.RecoveryCodes = codes
+	app.render(w, http.StatusOK, "twofactor_recovery.html", data)
+}
+
+func (app *application) accountTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = twoFactorDisableForm{}
+	app.render(w, http.StatusOK, "twofactor_disable.html", data)
+}
+
+// accountTwoFactorDisablePost turns off two-factor authentication. The user
+// has to give their password, so that someone using a session they left
+// logged in can't do it.
+func (app *application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	var form twoFactorDisableForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "twofactor_disable.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := app.users.Authenticate(user.Email, form.Password)
+	if err != nil || id != userID {
+		if err == nil || errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "twofactor_disable.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.twoFactor.Disable(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been turned off.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userSignupForm{}
+	app.render(w, http.StatusOK, "signup.html", data)
+}
+
+func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+	// Declare an zero-valued instance of our userSignupForm struct.
+	var form userSignupForm
+
+	// Parse the form data into the userSignupForm struct.
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Perform form data validation
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+
+	// If there are any errors, redisplay the signup form along with a 422
+	// status code.
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+		return
+	}
+
+	// Try to create a new user record in the database. If the email address
+	// is already registered, the response is the same as for a new account,
+	// so that signing up can't be used to find out who has one. The owner of
+	// the existing account is emailed instead, in case it was them.
+	err = app.users.Insert(form.Name, form.Email, form.Password)
+	if err != nil && !errors.Is(err, models.ErrDuplicateEmail) {
+		app.serverError(w, err)
+		return
+	}
+	duplicate := err != nil
+
+	app.background(func() {
+		user, err := app.users.GetByEmail(form.Email)
+		if err == nil {
+			if duplicate {
+				err = app.sendSignupExisting(user)
+			} else {
+				// Send the new user a link to verify their email address.
+				// Until they follow it they can log in, but can't create
+				// anything.
+				err = app.sendActivation(user)
+			}
+		}
+		if err != nil {
+			app.errorLog.Printf("signup email for %s: %s", form.Email, err)
+		}
+	})
+
+	// Add a flash message to the session, which reads the same whether or
+	// not the account is new.
+	app.sessionManager.Put(r.Context(), "flash", "Thanks for signing up! We've emailed you a link to verify your address. Please log in.")
+
+	// And redirect the user to the login page.
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userLoginForm{}
+	app.render(w, http.StatusOK, "login.html", data)
+}
+
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+	var form userLoginForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		return
+	}
+
+	ip := clientIP(r)
+
+	recs, wait, err := app.loginAttempt(form.Email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The password is checked even while logins are throttled, and failures
+	// are counted whether or not an account has the email address, so that
+	// neither the response nor the time it takes gives away which addresses
+	// are registered.
+	id, err := app.users.Authenticate(form.Email, form.Password)
+	if err != nil && !errors.Is(err, models.ErrInvalidCredentials) {
+		app.serverError(w, err)
+		return
+	}
+
+	if wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", formatWait(wait)))
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login.html", data)
+		return
+	}
+
+	if err != nil {
+		app.loginRejected(form.Email, ip, recs)
+
+		form.AddNonFieldError("Email or password is incorrect")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+		return
+	}
+
+	// The password was right, so the attempt isn't a failure. The failures
+	// for the account are only forgotten once the user is fully logged in.
+	err = app.loginAccepted(form.Email, ip, recs)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Users with two-factor authentication have to enter a code before
+	// they are logged in. Until then the session only remembers who they
+	// claim to be, and only for a few minutes.
+	enabled, err := app.twoFactor.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if enabled {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorUserID", id)
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorExpires", time.Now().Add(twoFactorLoginTTL).Unix())
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorAttempts", 0)
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorRemember", form.Remember)
+
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err = app.loginSucceeded(form.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.logIn(w, r, id, form.Remember)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.redirectAfterLogin(w, r)
+}
+
+// logIn starts an authenticated session for the user, once they have proved
+// who they are. If remember is true, they are also given a remember me
+// cookie, which logs them in again once the session expires.
+func (app *application) logIn(w http.ResponseWriter, r *http.Request, userID int, remember bool) error {
+	// Use the RenewToken() method on the current session to change the session
+	// ID. It's good practice to generate a new session ID when the
+	// authentication state or privilege levels changes for the user (e.g. login
+	// and logout operations).
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// Add the ID of the current user to the session, so that they are now
+	// 'logged in'.
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+
+	var selector string
+	if remember {
+		selector, err = app.issueRememberToken(w, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Index the new session token under the user, so that their sessions can
+	// be found later, along with any remember me token, so that revoking the
+	// session also stops the device from logging straight back in. Adding it
+	// counts as seeing it, so authenticate needn't touch it straight away.
+	err = app.sessions.Add(app.sessionManager.Token(r.Context()), userID, r.UserAgent(), clientIP(r), selector)
+	if err != nil {
+		return err
+	}
+	app.sessionManager.Put(r.Context(), "lastSeen", time.Now().Unix())
+
+	return nil
+}
+
+// redirectAfterLogin sends a user who has just logged in back to the page
+// they were trying to reach when they were asked to log in, or to the page
+// for creating a snippet if there wasn't one.
+func (app *application) redirectAfterLogin(w http.ResponseWriter, r *http.Request) {
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if !isSafeRedirect(path) {
+		path = "/snippet/create"
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// pendingTwoFactorUserID returns the ID of the user who has given their
+// password but not yet their second factor, or 0 if there isn't one or they
+// took too long.
+func (app *application) pendingTwoFactorUserID(r *http.Request) int {
+	id := app.sessionManager.GetInt(r.Context(), "pendingTwoFactorUserID")
+	if id == 0 || time.Now().Unix() > app.sessionManager.GetInt64(r.Context(), "pendingTwoFactorExpires") {
+		return 0
+	}
+	return id
+}
+
+// clearPendingTwoFactor forgets the user who was part way through logging in.
+func (app *application) clearPendingTwoFactor(r *http.Request) {
+	app.sessionManager.Remove(r.Context(), "pendingTwoFactorUserID")
+	app.sessionManager.Remove(r.Context(), "pendingTwoFactorExpires")
+	app.sessionManager.Remove(r.Context(), "pendingTwoFactorAttempts")
+	app.sessionManager.Remove(r.Context(), "pendingTwoFactorRemember")
+}
+
+func (app *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if app.pendingTwoFactorUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = userLoginTwoFactorForm{}
+	app.render(w, http.StatusOK, "login_2fa.html", data)
+}
+
+// userLoginTwoFactorPost finishes logging in a user with two-factor
+// authentication, given either a code from their authenticator app or one of
+// their recovery codes. After too many wrong codes they have to start again
+// with their password.
+func (app *application) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.pendingTwoFactorUserID(r)
+	if userID == 0 {
+		app.clearPendingTwoFactor(r)
+		app.sessionManager.Put(r.Context(), "flash", "Your login timed out. Please try again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userLoginTwoFactorForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_2fa.html", data)
+		return
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Wrong codes count towards locking the account as well, so that
+	// someone who knows the password can't keep starting again. Unlike the
+	// password, the code isn't checked at all while logins are throttled,
+	// since the user's account is already known.
+	ip := clientIP(r)
+
+	recs, wait, err := app.loginAttempt(user.Email, ip)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if wait > 0 {
+		form.AddNonFieldError(fmt.Sprintf("Too many failed login attempts. Please try again in %s.", formatWait(wait)))
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "login_2fa.html", data)
+		return
+	}
+
+	usedRecoveryCode := !isTOTPCode(form.Code)
+	if usedRecoveryCode {
+		err = app.twoFactor.UseRecoveryCode(userID, hashToken(normaliseRecoveryCode(form.Code)))
+	} else {
+		err = app.twoFactor.Validate(userID, strings.TrimSpace(form.Code), time.Now())
+	}
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			app.serverError(w, err)
+			return
+		}
+
+		app.loginRejected(user.Email, ip, recs)
+
+		attempts := app.sessionManager.GetInt(r.Context(), "pendingTwoFactorAttempts") + 1
+		if attempts >= twoFactorMaxAttempts {
+			app.clearPendingTwoFactor(r)
+			app.sessionManager.Put(r.Context(), "flash", "Too many incorrect codes. Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "pendingTwoFactorAttempts", attempts)
+
+		form.AddFieldError("code", "This code is incorrect")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_2fa.html", data)
+		return
+	}
+
+	remember := app.sessionManager.GetBool(r.Context(), "pendingTwoFactorRemember")
+	app.clearPendingTwoFactor(r)
+
+	err = app.loginAccepted(user.Email, ip, recs)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.loginSucceeded(user.Email)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.logIn(w, r, userID, remember)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if usedRecoveryCode {
+		left, err := app.twoFactor.RecoveryCodesLeft(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You used a recovery code and have %d left. Each code only works once.", left))
+	}
+
+	app.redirectAfterLogin(w, r)
+}
+
+func (app *application) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordForgotForm{}
+	app.render(w, http.StatusOK, "password_forgot.html", data)
+}
+
+// userPasswordForgotPost emails a password reset link to the given address,
+// if it belongs to a user. The response is the same whether or not it does,
+// and the lookup happens in the background so that the response time doesn't
+// give it away either.
+func (app *application) userPasswordForgotPost(w http.ResponseWriter, r *http.Request) {
+	ok, retry := app.passwordResetLimiter.Allow(clientIP(r))
+	if !ok {
+		app.tooManyRequests(w, retry)
+		return
+	}
+
+	var form userPasswordForgotForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "password_forgot.html", data)
+		return
+	}
+
+	app.background(func() {
+		err := app.sendPasswordReset(form.Email)
+		if err != nil {
+			app.errorLog.Printf("password reset for %s: %s", form.Email, err)
+		}
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "If an account uses that email address, we've sent it a link to reset the password.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// sendPasswordReset issues a password reset token for the user with the
+// given email address and emails them a link containing it. Nothing is sent
+// if there is no such user, or if too many links have been sent to the
+// address recently.
+func (app *application) sendPasswordReset(email string) error {
+	ok, _ := app.passwordResetLimiter.Allow("email:" + strings.ToLower(email))
+	if !ok {
+		return nil
+	}
+
+	user, err := app.users.GetByEmail(email)
+	if errors.Is(err, models.ErrNoRecord) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	err = app.tokens.Insert(tokenHash, user.ID, models.ScopePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return app.sendEmail(user.Email, "password_reset.txt", map[string]any{
+		"Name":    user.Name,
+		"URL":     app.config.baseURL + "/user/password/reset?token=" + url.QueryEscape(token),
+		"Expires": "1 hour",
+	})
+}
+
+func (app *application) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userPasswordResetForm{
+		Token: r.URL.Query().Get("token"),
+	}
+	app.render(w, http.StatusOK, "password_reset.html", data)
+}
+
+// userPasswordResetPost sets a new password for the user a reset token was
+// issued to, using up the token. Since the password may have been reset
+// because someone else knew it, all of the user's sessions are logged out.
+func (app *application) userPasswordResetPost(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordResetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+	// The token is only used up if the new password is saved with it.
+	var userID int
+	if form.Valid() {
+		userID, err = app.users.PasswordReset(hashToken(form.Token), form.NewPassword)
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddNonFieldError("This password reset link is invalid or has expired. Please ask for a new one.")
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "password_reset.html", data)
+		return
+	}
+
+	err = app.sessions.RevokeAll(userID, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.rememberTokens.DeleteAllForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// sendActivation issues an activation token for the user and emails them a
+// link containing it.
+func (app *application) sendActivation(user *models.User) error {
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	err = app.tokens.Insert(tokenHash, user.ID, models.ScopeActivation, activationTTL)
+	if err != nil {
+		return err
+	}
+
+	return app.sendEmail(user.Email, "activation.txt", map[string]any{
+		"Name":    user.Name,
+		"URL":     app.config.baseURL + "/user/activate?token=" + url.QueryEscape(token),
+		"Expires": "3 days",
+	})
+}
+
+// sendSignupExisting tells the owner of an account that someone tried to
+// sign up with their email address. These emails share the password reset
+// limit for the address, so that signing up repeatedly can't be used to
+// flood someone's inbox.
+func (app *application) sendSignupExisting(user *models.User) error {
+	ok, _ := app.passwordResetLimiter.Allow("email:" + strings.ToLower(user.Email))
+	if !ok {
+		return nil
+	}
+
+	return app.sendEmail(user.Email, "signup_existing.txt", map[string]any{
+		"Name":     user.Name,
+		"LoginURL": app.config.baseURL + "/user/login",
+		"ResetURL": app.config.baseURL + "/user/password/forgot",
+	})
+}
+
+// userActivate asks the user to confirm that they want to verify their email
+// address. The link in the email doesn't verify it by itself, since mail
+// scanners sometimes follow links.
+func (app *application) userActivate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userActivateForm{
+		Token: r.URL.Query().Get("token"),
+	}
+	app.render(w, http.StatusOK, "activate.html", data)
+}
+
+func (app *application) userActivatePost(w http.ResponseWriter, r *http.Request) {
+	var form userActivateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := app.tokens.Consume(hashToken(form.Token), models.ScopeActivation)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form.AddNonFieldError("This verification link is invalid or has expired. Log in to ask for a new one.")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "activate.html", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	err = app.users.Activate(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.tokens.DeleteAllForUser(models.ScopeActivation, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been verified!")
+
+	if app.isAuthenticated(r) {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// accountActivationResendPost sends the user another email verification
+// link, up to activationResendLimit times an hour.
+func (app *application) accountActivationResendPost(w http.ResponseWriter, r *http.Request) {
+	if app.isActivated(r) {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	ok, _ := app.activationLimiter.Allow(strconv.Itoa(userID))
+	if !ok {
+		app.sessionManager.Put(r.Context(), "flash", "We've sent you several emails recently. Please wait a while before asking for another.")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.background(func() {
+		err := app.sendActivation(user)
+		if err != nil {
+			app.errorLog.Printf("activation for %s: %s", user.Email, err)
+		}
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", "We've sent you another verification email.")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	err := app.logOut(w, r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
 	// Add a flash message to the session to confirm to the user that they've been logged out
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully")
@@ -315,6 +2363,121 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// logOut logs the user out of the current session.
+func (app *application) logOut(w http.ResponseWriter, r *http.Request) error {
+	err := app.sessions.Remove(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	// Logging out also stops the device from being remembered.
+	err = app.forgetRememberToken(w, r)
+	if err != nil {
+		return err
+	}
+
+	// Use the RenewToken() method on the current session to change the session
+	// ID again.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// Remove the authenticatedUserID from the session data so that the user
+	// is no longer logged in.
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	return nil
+}
+
+// accountSessions lists the sessions the user is logged in to, with the
+// browser and IP address each was started from.
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	sessions, err := app.sessions.List(userID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Sessions = sessions
+	app.render(w, http.StatusOK, "sessions.html", data)
+}
+
+// accountSessionRevokePost logs the user out of one of their other sessions.
+// The current session is ended by logging out instead, so that the user is
+// not left looking at pages they can no longer use.
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	var form sessionRevokeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	sessions, err := app.sessions.List(userID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	for _, s := range sessions {
+		if s.ID == form.ID && s.Current {
+			app.sessionManager.Put(r.Context(), "flash", "To end this session, log out.")
+			http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+			return
+		}
+	}
+
+	err = app.sessions.Revoke(userID, form.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "That session has been logged out.")
+
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// accountSessionsRevokeAllPost logs the user out everywhere: every other
+// session is revoked, remembered devices are forgotten, and then the current
+// session is logged out.
+func (app *application) accountSessionsRevokeAllPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	err := app.sessions.RevokeAll(userID, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.rememberTokens.DeleteAllForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.logOut(w, r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out everywhere.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 // ping writes "OK" to the http.ResponseWriter.
 // status-checking or uptime monitoring of the server.
 // w http.ResponseWriter
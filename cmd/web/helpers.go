@@ -2,13 +2,24 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/langdetect"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/spdx"
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
@@ -79,10 +90,16 @@ func (app *application) render(w http.ResponseWriter, status int, page string, t
 // It takes a pointer to an http.Request as its parameter and returns a pointer to a templateData struct.
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		IsActivated:         app.isActivated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
+		Languages:           langdetect.Languages,
+		Licenses:            spdx.Licenses,
+		AnonymousCreate:     app.config.anonymous,
+		TwoFactorAvailable:  app.config.twoFactor,
 	}
 }
 
@@ -139,3 +156,157 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// isActivated reports whether the logged in user has verified their email
+// address. It is false for guests.
+func (app *application) isActivated(r *http.Request) bool {
+	isActivated, ok := r.Context().Value(isActivatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+	return isActivated
+}
+
+// authenticatedUserID returns the ID of the logged in user from the session, or
+// 0 if the request does not belong to a logged in user.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// ownedSnippet fetches the live snippet named by the ":id" route parameter and
+// checks that it belongs to the logged in user. If the ID is invalid, the
+// snippet doesn't exist or it belongs to someone else, ownedSnippet sends the
+// appropriate error response and returns nil.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return snippet
+}
+
+// viewableSnippet fetches the live snippet named by the ":id" route parameter
+// and checks that the current user is allowed to see it. Otherwise it sends a
+// 404 response, as for a snippet that doesn't exist, and returns nil.
+func (app *application) viewableSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+
+	if !app.canView(r, snippet) {
+		app.notFound(w)
+		return nil
+	}
+
+	return snippet
+}
+
+// ownedCollection fetches the collection named by the ":id" route parameter and
+// checks that it belongs to the logged in user, in the same way as
+// ownedSnippet.
+func (app *application) ownedCollection(w http.ResponseWriter, r *http.Request) *models.Collection {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	collection, err := app.collections.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil
+	}
+
+	if collection.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return collection
+}
+
+// canView reports whether the current user may see a snippet. Published
+// snippets are visible to everyone; unpublished ones only to their owner.
+func (app *application) canView(r *http.Request, snippet *models.Snippet) bool {
+	if snippet.Published {
+		return true
+	}
+	return app.isAuthenticated(r) && snippet.UserID == app.authenticatedUserID(r)
+}
+
+// newToken returns a random, URL safe token, such as a guest's token for
+// deleting a snippet or a password reset token, along with the hash of it to
+// be stored.
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// isSafeRedirect reports whether path is a relative path on this site, and
+// so safe to redirect to. Anything which a browser could treat as another
+// origin, such as "//example.com" or "/\example.com", is rejected.
+func isSafeRedirect(path string) bool {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, "\\") {
+		return false
+	}
+	for _, c := range path {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+
+	u, err := url.Parse(path)
+	return err == nil && u.Scheme == "" && u.Host == "" && u.User == nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a token. Tokens are long
+// and random, so a fast, unsalted hash is enough to keep them safe at rest.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestIsSafeRedirect(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/snippet/view/1", want: true},
+		{path: "/collections?page=2&sort=name", want: true},
+		{path: "/", want: true},
+		{path: "", want: false},
+		{path: "snippet/view/1", want: false},
+		{path: "//evil.example", want: false},
+		{path: "/\\evil.example", want: false},
+		{path: "https://evil.example/", want: false},
+		{path: "/\tevil", want: false},
+		{path: "/%zz", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, isSafeRedirect(tt.path), tt.want)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/throttle"
+)
+
+// loginAccountPolicy slows down password guesses against one account, from
+// any number of IP addresses, and loginIPPolicy guesses from one IP address
+// against any number of accounts. The IP policy is looser, since several
+// users can share an address.
+var (
+	loginAccountPolicy = throttle.Policy{
+		Free:         3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 10,
+		LockoutFor:   15 * time.Minute,
+		Window:       time.Hour,
+	}
+	loginIPPolicy = throttle.Policy{
+		Free:         20,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 100,
+		LockoutFor:   15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+// Failures are counted by email address rather than user ID, so that
+// addresses without an account are throttled in exactly the same way as
+// those with one.
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginRecords are the failure records for the account and the IP address
+// of a login, counting the login itself.
+type loginRecords struct {
+	account throttle.Record
+	ip      throttle.Record
+}
+
+// loginAttempt counts a login for email from ip as a failure against both
+// the account and the IP address before the password is checked, so that
+// concurrent guesses can't all get past the throttle before any of them has
+// failed. If the login has to wait, it isn't counted and the wait is
+// returned. Otherwise it must be followed by loginRejected or loginAccepted.
+func (app *application) loginAttempt(email, ip string) (loginRecords, time.Duration, error) {
+	now := time.Now()
+
+	ipRec, wait, err := app.loginIPThrottle.Attempt(loginIPKey(ip), now)
+	if err != nil || wait > 0 {
+		return loginRecords{}, wait, err
+	}
+
+	accountRec, wait, err := app.loginAccountThrottle.Attempt(loginAccountKey(email), now)
+	if err != nil {
+		return loginRecords{}, 0, err
+	}
+	if wait > 0 {
+		// The login won't be tried after all, so the IP address gets its
+		// attempt back.
+		return loginRecords{}, wait, app.loginIPThrottle.Release(loginIPKey(ip), ipRec)
+	}
+
+	return loginRecords{account: accountRec, ip: ipRec}, 0, nil
+}
+
+// loginAccepted gives back the attempt counted by loginAttempt for a login
+// with the right password or code.
+func (app *application) loginAccepted(email, ip string, recs loginRecords) error {
+	err := app.loginAccountThrottle.Release(loginAccountKey(email), recs.account)
+	if err != nil {
+		return err
+	}
+
+	return app.loginIPThrottle.Release(loginIPKey(ip), recs.ip)
+}
+
+// loginRejected handles a failed login, once counted by loginAttempt, by
+// logging any lockout it caused. When the account becomes locked, its owner,
+// if there is one, is emailed in the background.
+func (app *application) loginRejected(email, ip string, recs loginRecords) {
+	if app.loginAccountThrottle.Locks(recs.account) {
+		failures := recs.account.Failures
+
+		app.infoLog.Printf("login: locked %s for %s after %d failed attempts, the last from %s",
+			email, app.loginAccountThrottle.Policy.LockoutFor, failures, ip)
+
+		app.background(func() {
+			err := app.sendLockoutNotice(email, ip, failures)
+			if err != nil {
+				app.errorLog.Printf("lockout notice for %s: %s", email, err)
+			}
+		})
+	}
+
+	if app.loginIPThrottle.Locks(recs.ip) {
+		app.infoLog.Printf("login: locked IP address %s for %s after too many failed attempts",
+			ip, app.loginIPThrottle.Policy.LockoutFor)
+	}
+}
+
+// loginSucceeded forgets the failed logins for an account. Failures from the
+// IP address are kept, so that logging in to one account doesn't let an
+// attacker carry on guessing the passwords of others.
+func (app *application) loginSucceeded(email string) error {
+	return app.loginAccountThrottle.Reset(loginAccountKey(email))
+}
+
+// sendLockoutNotice tells the owner of the account with the given email
+// address, if there is one, that it has been locked.
+func (app *application) sendLockoutNotice(email, ip string, failures int) error {
+	user, err := app.users.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	return app.sendEmail(user.Email, "account_locked.txt", map[string]any{
+		"Name":     user.Name,
+		"Failures": failures,
+		"IP":       ip,
+		"Duration": formatWait(loginAccountPolicy.LockoutFor),
+		"URL":      app.config.baseURL + "/user/password/forgot",
+	})
+}
+
+// formatWait describes a wait in whole seconds or minutes, rounding up.
+func formatWait(d time.Duration) string {
+	if d <= time.Minute {
+		n := int((d + time.Second - 1) / time.Second)
+		if n == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", n)
+	}
+
+	n := int((d + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("%d minutes", n)
+}
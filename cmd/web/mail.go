@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/mailer"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/ui"
+)
+
+// sendEmail renders the email template with the given name from ui/email,
+// which must define "subject" and "body" templates, and sends it to the
+// recipient.
+func (app *application) sendEmail(to, name string, data any) error {
+	tmpl, err := template.ParseFS(ui.Files, "email/"+name)
+	if err != nil {
+		return err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return err
+	}
+
+	body := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(body, "body", data)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.Send(mailer.Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    body.String(),
+	})
+}
+
+// background runs fn in its own goroutine, so that slow work such as sending
+// email doesn't hold up the response, and so that the response takes the
+// same time whatever fn finds. A panic in fn is recovered and logged.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Printf("background: %s", err)
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/mailer"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/related"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/secrets"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/throttle"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
@@ -18,17 +29,89 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
+// The config struct holds the feature settings for the application which are
+// read from command-line flags at startup.
+type config struct {
+	// dedupe controls whether snippetCreatePost offers to reuse one of the
+	// user's identical live snippets instead of creating a copy.
+	dedupe bool
+	// secretRules is the path of a JSON file with secret scanner rules. When
+	// empty the scanner's built-in rules are used.
+	secretRules string
+	// baseURL is the externally visible URL of the application, used to build
+	// absolute links in notifications.
+	baseURL string
+	// publishInterval is how often the scheduler looks for scheduled snippets
+	// which are due to be published, and publishWebhook is an optional URL
+	// which is notified about each one.
+	publishInterval time.Duration
+	publishWebhook  string
+	// maxFeatured is the most snippets admins may pin to the "Featured"
+	// section of the home page.
+	maxFeatured int
+	// trendingInterval is how often the trending snippets are rescored, and
+	// trendingHalfLife is how long it takes for a view, star or fork to count
+	// half as much towards a snippet's score.
+	trendingInterval time.Duration
+	trendingHalfLife time.Duration
+	// anonymous lets guests create snippets without logging in, up to
+	// anonymousLimit snippets per IP address per hour.
+	anonymous      bool
+	anonymousLimit int
+	// smtp configures the server used to send email. When smtp.Addr is empty,
+	// emails are written to the info log instead.
+	smtp mailer.SMTP
+	// quota holds the default limits on how much each user can store. Admins
+	// can override them for individual users.
+	quota models.Quota
+	// throttleStore is where failed logins are counted: "mysql" to share the
+	// counts between instances, or "memory".
+	throttleStore string
+	// twoFactor is true when users can turn on two-factor authentication.
+	// TOTP secrets are only ever stored encrypted, so it needs encryption
+	// keys; it isn't set by a flag.
+	twoFactor bool
+}
+
 // The application struct holds the application-wide dependencies for the Snippetbox
 // web application. Its purpose is to facilitate dependency injection thus avoiding
 // code duplication.
 type application struct {
+	config         config
 	errorLog       *log.Logger
 	infoLog        *log.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	collections    models.CollectionModelInterface
+	featured       models.FeaturedModelInterface
+	featuredCache  *featuredCache
+	trending       models.TrendingModelInterface
+	quotas         models.QuotaModelInterface
+	sessions       models.SessionModelInterface
+	tokens         models.TokenModelInterface
+	twoFactor      models.TwoFactorModelInterface
+	rememberTokens models.RememberTokenModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	secretScanner  *secrets.Scanner
+	// anonymousLimiter rate limits snippet creation by guests.
+	anonymousLimiter *ipLimiter
+	related          *related.Index
+	notifiers        []notifier
+	// passwordResetLimiter rate limits password reset requests, both by IP
+	// address and by email address.
+	passwordResetLimiter *ipLimiter
+	// activationLimiter rate limits verification emails by user ID.
+	activationLimiter *ipLimiter
+	mailer            mailer.Mailer
+	// loginAccountThrottle and loginIPThrottle slow down and lock out
+	// repeated failed logins, by account and by IP address.
+	loginAccountThrottle *throttle.Throttle
+	loginIPThrottle      *throttle.Throttle
+	// wg tracks the goroutines started by background(), such as those
+	// sending emails, so that main can wait for them before exiting.
+	wg sync.WaitGroup
 }
 
 func main() {
@@ -38,6 +121,31 @@ func main() {
 	addr := flag.String("addr", ":4000", "HTTP network address")
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
 
+	var cfg config
+	// The encryption keys are read from the environment by default, so that
+	// they don't have to appear on the command line.
+	encryptionKeys := flag.String("encryption-keys", os.Getenv("SNIPPETBOX_ENCRYPTION_KEYS"),
+		"Comma separated id:base64key pairs used to encrypt snippet content; the first is the primary key")
+
+	flag.BoolVar(&cfg.dedupe, "dedupe", true, "Offer to reuse an identical live snippet instead of creating a copy")
+	flag.StringVar(&cfg.secretRules, "secret-rules", "", "Path to a JSON file of secret scanner rules")
+	flag.StringVar(&cfg.baseURL, "base-url", "https://localhost:4000", "Externally visible base URL of the application")
+	flag.DurationVar(&cfg.publishInterval, "publish-interval", time.Minute, "How often to publish scheduled snippets")
+	flag.StringVar(&cfg.publishWebhook, "publish-webhook", "", "URL to POST to when a scheduled snippet is published")
+	flag.DurationVar(&cfg.trendingInterval, "trending-interval", 15*time.Minute, "How often to rescore trending snippets")
+	flag.DurationVar(&cfg.trendingHalfLife, "trending-half-life", 48*time.Hour, "Time for a view, star or fork to lose half its weight in the trending score")
+	flag.BoolVar(&cfg.anonymous, "anonymous", false, "Let guests create snippets without logging in")
+	flag.IntVar(&cfg.anonymousLimit, "anonymous-limit", 10, "Maximum snippets a guest may create per IP address per hour")
+	flag.IntVar(&cfg.quota.Snippets, "quota-snippets", 500, "Default maximum number of live snippets per user (-1 for no limit)")
+	flag.IntVar(&cfg.quota.Bytes, "quota-bytes", 5<<20, "Default maximum total size in bytes of each user's live snippets (-1 for no limit)")
+	flag.IntVar(&cfg.quota.PerHour, "quota-per-hour", 30, "Default maximum number of snippets each user may create per hour (-1 for no limit)")
+	flag.StringVar(&cfg.smtp.Addr, "smtp-addr", "", "SMTP server host:port; if empty, emails are logged instead of sent")
+	flag.StringVar(&cfg.smtp.Username, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.smtp.Password, "smtp-password", os.Getenv("SNIPPETBOX_SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&cfg.smtp.From, "smtp-sender", "Snippetbox <no-reply@snippetbox.example>", "Sender of the emails sent by the application")
+	flag.StringVar(&cfg.throttleStore, "throttle-store", "mysql", `Where to count failed logins: "mysql" or "memory"`)
+	flag.IntVar(&cfg.maxFeatured, "max-featured", 5, "Maximum number of snippets which can be featured on the home page")
+
 	// Importantly, we use the flag.Parse() function to parse the command-line flag.
 	// This reads in the command-line flag value and assigns it to the addr
 	// variable. You need to call this *before* you use the addr variable
@@ -71,6 +179,27 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	// Load the secret scanner rules from the configured file, falling back to
+	// the built-in rules if no file was given.
+	secretRules := secrets.DefaultRules()
+	if cfg.secretRules != "" {
+		secretRules, err = secrets.LoadRules(cfg.secretRules)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	// Snippet content is encrypted at rest when encryption keys are given.
+	// Without them, content is stored as plain text.
+	var keys *keyring.Keyring
+	if *encryptionKeys != "" {
+		keys, err = keyring.Parse(*encryptionKeys)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+	cfg.twoFactor = keys != nil
+
 	// Initialize a new form decoder.
 	formDecoder := form.NewDecoder()
 
@@ -84,16 +213,74 @@ func main() {
 	// This makes sure the cookie doesn't get sent over insecure connections
 	sessionManager.Cookie.Secure = true
 
+	// Failed logins are counted in MySQL by default, so that every instance
+	// of the application sees them.
+	var throttleStore throttle.Store
+	switch cfg.throttleStore {
+	case "mysql":
+		throttleStore = &models.ThrottleModel{DB: db}
+	case "memory":
+		throttleStore = throttle.NewMemoryStore()
+	default:
+		errorLog.Fatalf("unknown throttle store %q", cfg.throttleStore)
+	}
+
+	snippets := &models.SnippetModel{DB: db, Keys: keys}
+	featured := &models.FeaturedModel{DB: db, Keys: keys}
+
+	// Build the in-memory index used to suggest related snippets. It is kept
+	// up to date as snippets are created and changed.
+	relatedIndex, err := buildRelatedIndex(snippets)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		config:           cfg,
+		errorLog:         errorLog,
+		infoLog:          infoLog,
+		snippets:         snippets,
+		users:            &models.UserModel{DB: db},
+		collections:      &models.CollectionModel{DB: db, Keys: keys},
+		featured:         featured,
+		featuredCache:    newFeaturedCache(featured, featuredCacheTTL),
+		trending:         &models.TrendingModel{DB: db, Keys: keys},
+		quotas:           &models.QuotaModel{DB: db},
+		sessions:         &models.SessionModel{DB: db},
+		tokens:           &models.TokenModel{DB: db},
+		twoFactor:        &models.TwoFactorModel{DB: db, Keys: keys},
+		rememberTokens:   &models.RememberTokenModel{DB: db},
+		templateCache:    templateCache,
+		formDecoder:      formDecoder,
+		sessionManager:   sessionManager,
+		secretScanner:    secrets.New(secretRules...),
+		related:          relatedIndex,
+		anonymousLimiter: newIPLimiter(cfg.anonymousLimit, time.Hour),
+
+		passwordResetLimiter: newIPLimiter(passwordResetLimit, time.Hour),
+		activationLimiter:    newIPLimiter(activationResendLimit, time.Hour),
+		loginAccountThrottle: &throttle.Throttle{Store: throttleStore, Policy: loginAccountPolicy},
+		loginIPThrottle:      &throttle.Throttle{Store: throttleStore, Policy: loginIPPolicy},
 	}
 
+	if cfg.smtp.Addr != "" {
+		app.mailer = &cfg.smtp
+	} else {
+		app.mailer = &mailer.Log{Logger: infoLog}
+	}
+
+	if cfg.publishWebhook != "" {
+		app.notifiers = append(app.notifiers, newWebhookNotifier(cfg.publishWebhook, cfg.baseURL))
+	}
+
+	// Start the background scheduler which publishes scheduled snippets once
+	// their publish time has passed.
+	go app.publishScheduled(cfg.publishInterval)
+
+	// Start the background job which keeps the trending snippets table up to
+	// date, so that the trending page is a simple indexed read.
+	go app.recomputeTrending(cfg.trendingInterval, cfg.trendingHalfLife)
+
 	// Initialize a tls.Config struct to hold the non-default TLS settings we
 	// want the server to use. In this case the only thing that we're changing
 	// is the curve preferences value, so that only elliptic curves with
@@ -122,12 +309,46 @@ func main() {
 	// log.Printf() function to interpolate the address with the log message.
 	infoLog.Printf("Starting server on %s", *addr)
 
+	// When the process is asked to stop, stop accepting requests, let those in
+	// flight finish, and then wait for any background jobs, so that emails
+	// which are still being sent aren't lost.
+	shutdownErr := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		infoLog.Printf("Shutting down server (%s)", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownErr <- err
+			return
+		}
+
+		infoLog.Print("Waiting for background jobs to finish")
+		app.wg.Wait()
+		shutdownErr <- nil
+	}()
+
 	// Use the ListenAndServeTLS() method to start the HTTPS server. We
 	// pass in the paths to the TLS certificate and corresponding private key as
-	// the two parameters.
+	// the two parameters. It returns http.ErrServerClosed as soon as Shutdown()
+	// is called, so we then wait for the shutdown to complete.
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		errorLog.Fatal(err)
+	}
 
-	errorLog.Fatal(err)
+	infoLog.Print("Stopped server")
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pool
@@ -2,22 +2,41 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
 	"github.com/justinas/nosurf"
 )
 
+// sessionTouchInterval is how often a session's last seen time is updated
+// while it is in use.
+const sessionTouchInterval = time.Minute
+
 // secureHeaders sets secure headers for the HTTP response.
 //
 // It takes a `next` http.Handler as a parameter.
 // It does not return any values.
+//
+// The secret snippet pages get a stricter policy. They handle plaintext and
+// keys in the browser, so they may only load scripts and styles from this
+// site, may not make any requests from script, and never send a referrer.
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
-		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		if strings.HasPrefix(r.URL.Path, "/secret/") {
+			w.Header().Set("Content-Security-Policy",
+				"default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self'; "+
+					"connect-src 'none'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'")
+			w.Header().Set("Referrer-Policy", "no-referrer")
+		} else {
+			w.Header().Set("Content-Security-Policy",
+				"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+			w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+		}
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
 		w.Header().Set("X-XSS-Protection", "0")
@@ -64,8 +83,14 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If the user is not authenticated, redirect them to the login page and
 		// return from the middleware chain so that no subsequent handlers in
-		// the chain are executed.
+		// the chain are executed. The page they asked for is remembered, so
+		// they can be sent back to it once they have logged in. Only GET
+		// requests are remembered, since the redirect after login can't
+		// repeat a form submission.
 		if !app.isAuthenticated(r) {
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
@@ -79,6 +104,42 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireActivation stops logged in users who haven't verified their email
+// address yet, sending them to their account page where they can ask for
+// another verification email. Guests are let through, so that it can be used
+// alongside requireAuthentication or on routes open to guests.
+func (app *application) requireActivation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.isAuthenticated(r) && !app.isActivated(r) {
+			app.sessionManager.Put(r.Context(), "flash", "Please verify your email address first. Follow the link in the email we sent you.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin only lets requests from administrators through, and responds
+// with 403 Forbidden to everyone else. It must come after requireAuthentication
+// in the middleware chain.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isAdmin, err := app.users.IsAdmin(app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if !isAdmin {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // noSurf generates a CSRF protection middleware which uses a customized CSRF cookie with
 // the Secure, Path and HttpOnly attributes set.
 //
@@ -102,6 +163,18 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// "authenticatedUserID" value is in the session -- in which case we
 		// call the next handler in the chain as normal and return.
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+		// A user without a logged in session may have a remember me cookie,
+		// which logs them in again.
+		if id == 0 {
+			var err error
+			id, err = app.restoreRememberedSession(w, r)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+		}
+
 		if id == 0 {
 			next.ServeHTTP(w, r)
 			return
@@ -109,8 +182,8 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		// Otherwise, we check to see if a user with that ID exists in our
 		// database.
-		exists, err := app.users.Exists(id)
-		if err != nil {
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			app.serverError(w, err)
 			return
 		}
@@ -118,9 +191,25 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// If a matching user is found, we know that the request is
 		// coming from an authenticated user who exists in our database. We
 		// create a new copy of the request (with an isAuthenticatedContextKey
-		// value of true in the request context) and assign it to r.
-		if exists {
+		// value of true in the request context) and assign it to r. Whether
+		// they have verified their email address is recorded too.
+		if user != nil {
+			// Record that the session is still in use, for the list of the
+			// user's sessions. When it was last recorded is kept in the
+			// session too, so that most requests don't need to write to the
+			// database, or read from it to find out.
+			now := time.Now().Unix()
+			if now-app.sessionManager.GetInt64(r.Context(), "lastSeen") >= int64(sessionTouchInterval.Seconds()) {
+				err = app.sessions.Touch(app.sessionManager.Token(r.Context()))
+				if err != nil {
+					app.serverError(w, err)
+					return
+				}
+				app.sessionManager.Put(r.Context(), "lastSeen", now)
+			}
+
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, isActivatedContextKey, user.Activated)
 			r = r.WithContext(ctx)
 		}
 
@@ -61,3 +61,24 @@ func TestSecureHeaders(t *testing.T) {
 	bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestSecureHeadersSecretPages(t *testing.T) {
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "/secret/view/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	secureHeaders(next).ServeHTTP(rr, r)
+	rs := rr.Result()
+
+	// The secret pages don't allow anything from other sites, or any requests
+	// from script.
+	expectedValue := "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self'; " +
+		"connect-src 'none'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'"
+	assert.Equal(t, rs.Header.Get("Content-Security-Policy"), expectedValue)
+	assert.Equal(t, rs.Header.Get("Referrer-Policy"), "no-referrer")
+	assert.Equal(t, rs.Header.Get("X-Frame-Options"), "deny")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/validator"
+)
+
+// quotaFor returns the limits which apply to a user: the override set for
+// them by an admin if there is one, or the configured defaults.
+func (app *application) quotaFor(userID int) (models.Quota, error) {
+	quota, err := app.quotas.Get(userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return app.config.quota, nil
+	} else if err != nil {
+		return models.Quota{}, err
+	}
+	return *quota, nil
+}
+
+// checkQuota adds errors to v for any of the user's limits which creating a
+// snippet of size bytes would exceed. An error about the size is added to the
+// given field, while the limits on the number of snippets aren't about any one
+// field. The quota is returned so that it can be enforced again when the
+// snippet is inserted, in case other snippets were created in the meantime.
+func (app *application) checkQuota(v *validator.Validator, field string, userID, size int) (*models.Quota, error) {
+	quota, err := app.quotaFor(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := app.quotas.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if quota.SnippetsFull(usage) {
+		v.AddNonFieldError(fmt.Sprintf("You already have %d live snippets, which is your limit. Wait for some to expire before creating more.", usage.Snippets))
+	}
+	if quota.HourFull(usage) {
+		v.AddNonFieldError(fmt.Sprintf("You have reached your limit of %d new snippets an hour. Please try again later.", quota.PerHour))
+	}
+	if quota.TooLarge(usage, size) {
+		v.AddFieldError(field, fmt.Sprintf("This snippet would take you over your storage limit of %s; you have %s left",
+			formatBytes(quota.Bytes), formatBytes(max(quota.Bytes-usage.Bytes, 0))))
+	}
+
+	return &quota, nil
+}
+
+// overQuotaMessage is shown when a snippet passed checkQuota but no longer
+// fitted by the time it was saved.
+const overQuotaMessage = "This snippet would take you over your quota. Please try again."
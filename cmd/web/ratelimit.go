@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ipLimiter allows each client IP address a fixed number of requests per
+// window. Counts are kept in memory, so they are per process and reset on
+// restart.
+type ipLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*ipWindow
+	swept   time.Time
+}
+
+type ipWindow struct {
+	start time.Time
+	count int
+}
+
+func newIPLimiter(limit int, window time.Duration) *ipLimiter {
+	return &ipLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*ipWindow),
+	}
+}
+
+// Allow records a request from ip and reports whether it is within the limit.
+// If it isn't, it also returns how long until the client may try again.
+func (l *ipLimiter) Allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.clients[ip]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &ipWindow{start: now}
+		l.clients[ip] = w
+	}
+
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// sweep forgets clients whose window has ended, at most once per window, so
+// that the map doesn't grow without bound.
+func (l *ipLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+	for ip, w := range l.clients {
+		if now.Sub(w.start) >= l.window {
+			delete(l.clients, ip)
+		}
+	}
+	l.swept = now
+}
+
+// clientIP returns the IP address a request came from. Forwarding headers are
+// ignored, since the application is served directly rather than through a
+// proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitAnonymous rate limits requests from guests by IP address, and responds
+// with 429 Too Many Requests once a guest is over the limit. Requests from
+// logged in users are not limited.
+func (app *application) limitAnonymous(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			ok, retry := app.anonymousLimiter.Allow(clientIP(r))
+			if !ok {
+				app.tooManyRequests(w, retry)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tooManyRequests sends a 429 Too Many Requests response, telling the client
+// how long to wait before trying again.
+func (app *application) tooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+	app.clientError(w, http.StatusTooManyRequests)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/langdetect"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// snippetHeader returns the license and attribution details of a snippet as
+// a comment suited to its language, followed by a blank line. It returns an
+// empty string if the snippet has no such details, or if its language has no
+// comment syntax (JSON), since a header would make the content invalid. Plain
+// text snippets, and those with no language, get the details as plain lines.
+// Every line is commented, even if a detail somehow contains a line break, so
+// that nothing in the header can run as code.
+func snippetHeader(s *models.Snippet) string {
+	var lines []string
+	if s.License != "" {
+		lines = append(lines, "SPDX-License-Identifier: "+s.License)
+	}
+	if s.Attribution != "" {
+		lines = append(lines, "Attribution: "+s.Attribution)
+	}
+	if s.SourceURL != "" {
+		lines = append(lines, "Source: "+s.SourceURL)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	lang, _ := langdetect.Lookup(s.Language)
+	if lang.Comment == "" && s.Language != "text" && s.Language != "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		for _, l := range strings.FieldsFunc(line, func(r rune) bool { return r == '\n' || r == '\r' }) {
+			if lang.Comment != "" {
+				b.WriteString(lang.Comment + " ")
+			}
+			b.WriteString(l + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// snippetFile returns the content of a snippet as served by the raw and
+// download routes, with the license header prepended. A "#!" line must stay
+// first for the snippet to run as a script, so the header goes after it.
+func snippetFile(s *models.Snippet) string {
+	header := snippetHeader(s)
+	if header == "" {
+		return s.Content
+	}
+
+	if strings.HasPrefix(s.Content, "#!") {
+		shebang, rest, _ := strings.Cut(s.Content, "\n")
+		return shebang + "\n" + header + rest
+	}
+	return header + s.Content
+}
+
+// snippetFilename returns the name a snippet is downloaded as, with an
+// extension for its language.
+func snippetFilename(s *models.Snippet) string {
+	ext := ".txt"
+	if lang, ok := langdetect.Lookup(s.Language); ok {
+		ext = lang.Extension
+	}
+	return fmt.Sprintf("snippet-%d%s", s.ID, ext)
+}
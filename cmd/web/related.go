@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/related"
+)
+
+// relatedSize is the number of related snippets shown on the view page.
+const relatedSize = 5
+
+// buildRelatedIndex indexes every live, published snippet. Snippets don't have
+// tags of their own, so each snippet's language is used as its only tag.
+func buildRelatedIndex(snippets models.SnippetModelInterface) (*related.Index, error) {
+	all, err := snippets.AllPublished()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := related.New()
+	for _, s := range all {
+		ix.Add(relatedDocument(s))
+	}
+	return ix, nil
+}
+
+func relatedDocument(s *models.Snippet) related.Document {
+	return related.Document{
+		ID:      s.ID,
+		Tags:    []string{s.Language},
+		Title:   s.Title,
+		Content: s.Content,
+	}
+}
+
+// indexSnippet adds a snippet to the related snippets index, or updates it if
+// it is already there. Unpublished snippets are left out until they go live.
+func (app *application) indexSnippet(s *models.Snippet) {
+	if s.Published {
+		app.related.Add(relatedDocument(s))
+	}
+}
+
+// relatedSnippets returns the snippets most similar to s. Snippets which have
+// expired since they were indexed are dropped from the index as they are
+// found.
+func (app *application) relatedSnippets(s *models.Snippet) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+
+	for _, id := range app.related.Related(s.ID, relatedSize) {
+		snippet, err := app.snippets.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.related.Remove(id)
+				continue
+			}
+			return nil, err
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, nil
+}
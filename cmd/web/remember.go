@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+const (
+	rememberCookieName = "remember_me"
+	// rememberTTL is how long a user who ticks "Remember me" stays logged in
+	// on that device without using the site.
+	rememberTTL = 30 * 24 * time.Hour
+)
+
+// issueRememberToken creates a remember me token for the user and sets it
+// as a persistent cookie, returning the token's selector. The cookie holds
+// the selector and the validator, separated by a colon.
+func (app *application) issueRememberToken(w http.ResponseWriter, userID int) (string, error) {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	selector := base64.RawURLEncoding.EncodeToString(b)
+
+	validator, validatorHash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = app.rememberTokens.Insert(selector, validatorHash, userID, rememberTTL)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    selector + ":" + validator,
+		Path:     "/",
+		MaxAge:   int(rememberTTL.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return selector, nil
+}
+
+// rememberCookie returns the selector and validator from the request's
+// remember me cookie, if it has a well formed one.
+func rememberCookie(r *http.Request) (string, string, bool) {
+	cookie, err := r.Cookie(rememberCookieName)
+	if err != nil {
+		return "", "", false
+	}
+
+	selector, validator, found := strings.Cut(cookie.Value, ":")
+	if !found || selector == "" || validator == "" {
+		return "", "", false
+	}
+	return selector, validator, true
+}
+
+// clearRememberCookie tells the browser to delete its remember me cookie.
+func clearRememberCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rememberCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// restoreRememberedSession logs in the user whose remember me cookie came
+// with the request, returning their ID, or 0 if there is no valid cookie.
+// Each token works once: it is replaced with a new one as it is used, so a
+// stolen cookie stops working as soon as either copy is used. Tokens are
+// only issued once a user has fully logged in, including any second factor,
+// so they don't ask for it again.
+func (app *application) restoreRememberedSession(w http.ResponseWriter, r *http.Request) (int, error) {
+	selector, validator, ok := rememberCookie(r)
+	if !ok {
+		return 0, nil
+	}
+
+	token, err := app.rememberTokens.Claim(selector)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			clearRememberCookie(w)
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// A right selector with the wrong validator means the selector has
+	// leaked, most likely with a stolen cookie, so every device the user
+	// is remembered on has to log in again.
+	if subtle.ConstantTimeCompare([]byte(hashToken(validator)), []byte(token.ValidatorHash)) != 1 {
+		app.infoLog.Printf("remember me: wrong validator for user %d from %s", token.UserID, clientIP(r))
+		clearRememberCookie(w)
+		return 0, app.rememberTokens.DeleteAllForUser(token.UserID)
+	}
+
+	err = app.logIn(w, r, token.UserID, true)
+	if err != nil {
+		return 0, err
+	}
+
+	return token.UserID, nil
+}
+
+// forgetRememberToken deletes the remember me token the request came with,
+// if any, and the cookie holding it.
+func (app *application) forgetRememberToken(w http.ResponseWriter, r *http.Request) error {
+	selector, _, ok := rememberCookie(r)
+	if !ok {
+		return nil
+	}
+
+	clearRememberCookie(w)
+	return app.rememberTokens.Delete(selector)
+}
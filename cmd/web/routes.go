@@ -55,19 +55,83 @@ func (app *application) routes() http.Handler {
 	// These routes are unprotected, so they don't require authentication.
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/secret/view/:id", dynamic.ThenFunc(app.secretView))
+	router.Handler(http.MethodGet, "/snippet/delete/:id", dynamic.ThenFunc(app.snippetDelete))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", dynamic.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodGet, "/c/:slug", dynamic.ThenFunc(app.collectionView))
+	router.Handler(http.MethodGet, "/trending", dynamic.ThenFunc(app.trendingList))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactor))
+	router.Handler(http.MethodPost, "/user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactorPost))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userPasswordForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.ThenFunc(app.userPasswordForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset", dynamic.ThenFunc(app.userPasswordReset))
+	router.Handler(http.MethodPost, "/user/password/reset", dynamic.ThenFunc(app.userPasswordResetPost))
+	router.Handler(http.MethodGet, "/user/activate", dynamic.ThenFunc(app.userActivate))
+	router.Handler(http.MethodPost, "/user/activate", dynamic.ThenFunc(app.userActivatePost))
 
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
 	protected := dynamic.Append(app.requireAuthentication)
 
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodPost, "/account/activation/resend", protected.ThenFunc(app.accountActivationResendPost))
+	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/2fa/enable", protected.ThenFunc(app.accountTwoFactorEnable))
+	router.Handler(http.MethodPost, "/account/2fa/enable", protected.ThenFunc(app.accountTwoFactorEnablePost))
+	router.Handler(http.MethodGet, "/account/2fa/disable", protected.ThenFunc(app.accountTwoFactorDisable))
+	router.Handler(http.MethodPost, "/account/2fa/disable", protected.ThenFunc(app.accountTwoFactorDisablePost))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(app.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/revoke", protected.ThenFunc(app.accountSessionRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-all", protected.ThenFunc(app.accountSessionsRevokeAllPost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
 
+	// Routes which change anything other than the user's own account also
+	// need the user to have verified their email address.
+	activated := protected.Append(app.requireActivation)
+
+	// In anonymous mode guests may create snippets as well, subject to a
+	// rate limit; otherwise creating snippets requires logging in.
+	if app.config.anonymous {
+		router.Handler(http.MethodGet, "/snippet/create", dynamic.Append(app.requireActivation).ThenFunc(app.snippetCreate))
+		router.Handler(http.MethodPost, "/snippet/create", dynamic.Append(app.limitAnonymous, app.requireActivation).ThenFunc(app.snippetCreatePost))
+	} else {
+		router.Handler(http.MethodGet, "/snippet/create", activated.ThenFunc(app.snippetCreate))
+		router.Handler(http.MethodPost, "/snippet/create", activated.ThenFunc(app.snippetCreatePost))
+	}
+	router.Handler(http.MethodGet, "/secret/create", activated.ThenFunc(app.secretCreate))
+	router.Handler(http.MethodPost, "/secret/create", activated.ThenFunc(app.secretCreatePost))
+	router.Handler(http.MethodPost, "/snippet/language/:id", activated.ThenFunc(app.snippetLanguagePost))
+	router.Handler(http.MethodPost, "/snippet/format/:id", activated.ThenFunc(app.snippetFormatPost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", activated.ThenFunc(app.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:id", activated.ThenFunc(app.snippetUnstarPost))
+	router.Handler(http.MethodGet, "/collections", activated.ThenFunc(app.collectionList))
+	router.Handler(http.MethodGet, "/collection/create", activated.ThenFunc(app.collectionCreate))
+	router.Handler(http.MethodPost, "/collection/create", activated.ThenFunc(app.collectionCreatePost))
+	router.Handler(http.MethodGet, "/collection/edit/:id", activated.ThenFunc(app.collectionEdit))
+	router.Handler(http.MethodPost, "/collection/edit/:id", activated.ThenFunc(app.collectionEditPost))
+	router.Handler(http.MethodPost, "/collection/delete/:id", activated.ThenFunc(app.collectionDeletePost))
+	router.Handler(http.MethodPost, "/collection/add/:id", activated.ThenFunc(app.collectionAddPost))
+	router.Handler(http.MethodPost, "/collection/remove/:id", activated.ThenFunc(app.collectionRemovePost))
+	router.Handler(http.MethodPost, "/collection/move/:id", activated.ThenFunc(app.collectionMovePost))
+
+	// Administration routes, which are only available to admins.
+	admin := activated.Append(app.requireAdmin)
+
+	router.Handler(http.MethodGet, "/admin/featured", admin.ThenFunc(app.adminFeatured))
+	router.Handler(http.MethodPost, "/admin/featured/pin", admin.ThenFunc(app.adminFeaturedPinPost))
+	router.Handler(http.MethodPost, "/admin/featured/unpin", admin.ThenFunc(app.adminFeaturedUnpinPost))
+	router.Handler(http.MethodPost, "/admin/featured/move", admin.ThenFunc(app.adminFeaturedMovePost))
+	router.Handler(http.MethodGet, "/admin/quotas", admin.ThenFunc(app.adminQuotas))
+	router.Handler(http.MethodPost, "/admin/quotas/set", admin.ThenFunc(app.adminQuotasSetPost))
+	router.Handler(http.MethodPost, "/admin/quotas/remove", admin.ThenFunc(app.adminQuotasRemovePost))
+
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 
 	return standard.Then(router)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// notifier is implemented by anything that should be told when a scheduled
+// snippet goes live.
+type notifier interface {
+	SnippetPublished(s *models.Snippet) error
+}
+
+// webhookNotifier POSTs a small JSON document describing each newly published
+// snippet to a configured URL.
+type webhookNotifier struct {
+	url     string
+	baseURL string
+	client  *http.Client
+}
+
+func newWebhookNotifier(url, baseURL string) *webhookNotifier {
+	return &webhookNotifier{
+		url:     url,
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *webhookNotifier) SnippetPublished(s *models.Snippet) error {
+	body, err := json.Marshal(map[string]any{
+		"id":        s.ID,
+		"title":     s.Title,
+		"url":       fmt.Sprintf("%s/snippet/view/%d", n.baseURL, s.ID),
+		"published": s.PublishAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	rs, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", rs.Status)
+	}
+	return nil
+}
+
+// publishScheduled checks for scheduled snippets which are due every interval,
+// publishes them and notifies each of the configured notifiers. It is meant to
+// be run in its own goroutine for the lifetime of the application.
+func (app *application) publishScheduled(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.publishDue()
+	}
+}
+
+// publishDue runs a single round of the scheduler. A panic is recovered and
+// logged so that it doesn't bring down the whole application.
+func (app *application) publishDue() {
+	defer func() {
+		if err := recover(); err != nil {
+			app.errorLog.Printf("scheduler: %s", err)
+		}
+	}()
+
+	snippets, err := app.snippets.PublishDue()
+	if err != nil {
+		app.errorLog.Printf("scheduler: %s", err)
+		return
+	}
+
+	for _, s := range snippets {
+		app.infoLog.Printf("scheduler: published snippet %d", s.ID)
+		app.indexSnippet(s)
+		for _, n := range app.notifiers {
+			err := n.SnippetPublished(s)
+			if err != nil {
+				app.errorLog.Printf("scheduler: notifying about snippet %d: %s", s.ID, err)
+			}
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/gosource"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/langdetect"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/spdx"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/ui"
 )
 
@@ -14,13 +21,137 @@ import (
 // The Snippet field is a pointer to a models.Snippet object, and the Snippets field
 // is a slice of models.Snippet objects.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Collection          *models.Collection
+	Collections         []*models.Collection
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	IsActivated         bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	Languages           []langdetect.Language
+	Licenses            []spdx.License
+	SyntaxErrors        []gosource.SyntaxError
+	Lines               []snippetLine
+	Excerpt             string
+	Featured            []*models.Snippet
+	MaxFeatured         int
+	Starred             bool
+	Related             []*models.Snippet
+	// AnonymousCreate is true if guests may create snippets, and DeleteURL
+	// is the secret link a guest can use to delete the snippet they created.
+	AnonymousCreate bool
+	DeleteURL       string
+	// Quota and Usage are the limits which apply to a user and how much of
+	// them they have used, and QuotaOverrides lists the users whose limits
+	// have been changed by an admin.
+	Quota          models.Quota
+	Usage          *models.Usage
+	QuotaOverrides []*models.QuotaOverride
+	// User, SnippetCounts and ActiveSessions describe the logged in user's
+	// account.
+	User           *models.User
+	SnippetCounts  *models.SnippetCounts
+	ActiveSessions int
+	Sessions       []*models.Session
+	// TwoFactorAvailable is true if users can turn on two-factor
+	// authentication, and TwoFactorEnabled if the logged in user has.
+	TwoFactorAvailable bool
+	TwoFactorEnabled   bool
+	RecoveryCodesLeft  int
+	// TOTPSecret and QRCode are shown while enrolling in two-factor
+	// authentication, and RecoveryCodes once it has been turned on.
+	TOTPSecret    string
+	QRCode        template.HTML
+	RecoveryCodes []string
+}
+
+// snippetLine is a single numbered line of snippet content, as rendered on the
+// view page.
+type snippetLine struct {
+	Number      int
+	Text        string
+	Highlighted bool
+}
+
+// lineRange is an inclusive range of line numbers, as given by the "lines"
+// query string parameter, for example "10-20" or just "10".
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// parseLineRange parses a line range of the form "N" or "N-M". It returns
+// false if s isn't a valid range.
+func parseLineRange(s string) (lineRange, bool) {
+	first, last, found := strings.Cut(s, "-")
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 1 {
+		return lineRange{}, false
+	}
+	if !found {
+		return lineRange{Start: start, End: start}, true
+	}
+
+	end, err := strconv.Atoi(last)
+	if err != nil || end < start {
+		return lineRange{}, false
+	}
+	return lineRange{Start: start, End: end}, true
+}
+
+// snippetLines splits content into numbered lines, marking those inside the
+// highlight range. A zero lineRange highlights nothing.
+func snippetLines(content string, highlight lineRange) []snippetLine {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	texts := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	lines := make([]snippetLine, len(texts))
+	for i, text := range texts {
+		n := i + 1
+		lines[i] = snippetLine{
+			Number:      n,
+			Text:        text,
+			Highlighted: n >= highlight.Start && n <= highlight.End,
+		}
+	}
+	return lines
+}
+
+// maxExcerptLength is the maximum length in bytes of the plain text excerpt
+// used in link previews.
+const maxExcerptLength = 300
+
+// excerpt returns a plain text excerpt of the lines for use in link previews.
+// If any lines are highlighted, the excerpt is made up of just those lines;
+// otherwise it starts from the first line.
+func excerpt(lines []snippetLine) string {
+	var selected []string
+	for _, line := range lines {
+		if line.Highlighted {
+			selected = append(selected, line.Text)
+		}
+	}
+	if selected == nil {
+		for _, line := range lines {
+			selected = append(selected, line.Text)
+		}
+	}
+
+	text := strings.TrimSpace(strings.Join(selected, "\n"))
+	if len(text) > maxExcerptLength {
+		// Cut on a rune boundary so that the excerpt stays valid UTF-8.
+		cut := maxExcerptLength
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "…"
+	}
+	return text
 }
 
 // humanDate returns a formatted string representation of the given time.
@@ -36,11 +167,37 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// percent formats a fraction between 0 and 1 as a whole percentage, for
+// example 0.834 becomes "83%".
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
+// formatBytes formats a size in bytes using binary units, for example 1536
+// becomes "1.5 KB".
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	size, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if size < unit {
+			break
+		}
+		size, suffix = size/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", size, suffix)
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":    humanDate,
+	"languageName": langdetect.Name,
+	"percent":      percent,
+	"bytes":        formatBytes,
 }
 
 // newTemplateCache initializes a new template cache.
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -47,3 +48,103 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		want   lineRange
+		wantOK bool
+	}{
+		{name: "Single line", value: "10", want: lineRange{10, 10}, wantOK: true},
+		{name: "Range", value: "10-20", want: lineRange{10, 20}, wantOK: true},
+		{name: "Empty", value: ""},
+		{name: "Zero", value: "0"},
+		{name: "Reversed", value: "20-10"},
+		{name: "Not a number", value: "ten"},
+		{name: "Open range", value: "10-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLineRange(tt.value)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, ok, tt.wantOK)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	content := "line one\r\nline two\r\nline three\r\n"
+
+	lines := snippetLines(content, lineRange{})
+	assert.Equal(t, len(lines), 3)
+	assert.Equal(t, excerpt(lines), "line one\nline two\nline three")
+
+	lines = snippetLines(content, lineRange{2, 3})
+	assert.Equal(t, lines[0].Highlighted, false)
+	assert.Equal(t, lines[1].Highlighted, true)
+	assert.Equal(t, excerpt(lines), "line two\nline three")
+}
+
+func TestSnippetFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "No license",
+			snippet: &models.Snippet{Language: "go", Content: "package main\n"},
+			want:    "package main\n",
+		},
+		{
+			name:    "Go",
+			snippet: &models.Snippet{Language: "go", License: "MIT", Content: "package main\n"},
+			want:    "// SPDX-License-Identifier: MIT\n\npackage main\n",
+		},
+		{
+			name:    "SQL",
+			snippet: &models.Snippet{Language: "sql", License: "MIT", Attribution: "Alice", Content: "SELECT 1;"},
+			want:    "-- SPDX-License-Identifier: MIT\n-- Attribution: Alice\n\nSELECT 1;",
+		},
+		{
+			name:    "Shebang",
+			snippet: &models.Snippet{Language: "shell", License: "0BSD", Content: "#!/bin/sh\necho hi\n"},
+			want:    "#!/bin/sh\n# SPDX-License-Identifier: 0BSD\n\necho hi\n",
+		},
+		{
+			name:    "Attribution with a line break",
+			snippet: &models.Snippet{Language: "shell", License: "MIT", Attribution: "x\nrm -rf ~", Content: "echo hi\n"},
+			want:    "# SPDX-License-Identifier: MIT\n# Attribution: x\n# rm -rf ~\n\necho hi\n",
+		},
+		{
+			name:    "JSON has no comments",
+			snippet: &models.Snippet{Language: "json", License: "MIT", Content: "{}"},
+			want:    "{}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFile(tt.snippet), tt.want)
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{name: "Zero", n: 0, want: "0 bytes"},
+		{name: "Bytes", n: 1023, want: "1023 bytes"},
+		{name: "Kilobytes", n: 1536, want: "1.5 KB"},
+		{name: "Megabytes", n: 5 << 20, want: "5.0 MB"},
+		{name: "Gigabytes", n: 3 << 30, want: "3.0 GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, formatBytes(tt.n), tt.want)
+		})
+	}
+}
@@ -13,7 +13,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/mailer"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models/mocks"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/secrets"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/throttle"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 )
@@ -54,14 +58,47 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	snippets := &mocks.SnippetModel{}
+	featured := &mocks.FeaturedModel{}
+	rememberTokens := &mocks.RememberTokenModel{}
+
+	relatedIndex, err := buildRelatedIndex(snippets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{}, // Use the mock.
-		users:          &mocks.UserModel{},    // Use the mock.
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		config: config{
+			dedupe:      true,
+			maxFeatured: 5,
+			twoFactor:   true,
+			quota:       models.Quota{Snippets: models.NoLimit, Bytes: models.NoLimit, PerHour: models.NoLimit},
+		},
+		errorLog:         log.New(io.Discard, "", 0),
+		infoLog:          log.New(io.Discard, "", 0),
+		snippets:         snippets,           // Use the mock.
+		users:            &mocks.UserModel{}, // Use the mock.
+		collections:      &mocks.CollectionModel{},
+		featured:         featured,
+		featuredCache:    newFeaturedCache(featured, featuredCacheTTL),
+		trending:         &mocks.TrendingModel{},
+		quotas:           &mocks.QuotaModel{},
+		sessions:         &mocks.SessionModel{RememberTokens: rememberTokens},
+		templateCache:    templateCache,
+		formDecoder:      formDecoder,
+		sessionManager:   sessionManager,
+		secretScanner:    secrets.New(secrets.DefaultRules()...),
+		related:          relatedIndex,
+		anonymousLimiter: newIPLimiter(10, time.Hour),
+		tokens:           &mocks.TokenModel{},
+		twoFactor:        &mocks.TwoFactorModel{},
+		rememberTokens:   rememberTokens,
+		mailer:           &mailer.Log{Logger: log.New(io.Discard, "", 0)},
+
+		passwordResetLimiter: newIPLimiter(passwordResetLimit, time.Hour),
+		activationLimiter:    newIPLimiter(activationResendLimit, time.Hour),
+		loginAccountThrottle: &throttle.Throttle{Store: throttle.NewMemoryStore(), Policy: loginAccountPolicy},
+		loginIPThrottle:      &throttle.Throttle{Store: throttle.NewMemoryStore(), Policy: loginIPPolicy},
 	}
 }
 
@@ -126,3 +163,56 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// login logs the test server client in as the mocked user alice@example.com,
+// so that subsequent requests made with the same client are authenticated. It
+// returns a CSRF token which is valid for the logged in session.
+func (ts *testServer) login(t *testing.T) string {
+	return ts.loginAs(t, "alice@example.com")
+}
+
+// loginAs logs the test server client in as the mocked user with the given
+// email address, in the same way as login.
+func (ts *testServer) loginAs(t *testing.T, email string) string {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+	_, _, body = ts.get(t, "/account/view")
+	return extractCSRFToken(t, body)
+}
+
+// rememberCookieFrom returns the remember me cookie in the test client's
+// cookie jar, or nil if there isn't one.
+func (ts *testServer) rememberCookieFrom(t *testing.T) *http.Cookie {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range ts.Client().Jar.Cookies(u) {
+		if c.Name == rememberCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+// withOnlyCookie replaces the test client's cookies with just c, as if the
+// browser had been restarted after the session expired.
+func (ts *testServer) withOnlyCookie(t *testing.T, c *http.Cookie) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, []*http.Cookie{c})
+	ts.Client().Jar = jar
+}
@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// trendingWindow is how many half-lives of history are considered when scoring
+// trending snippets. After seven half-lives an event counts for less than 1%
+// of its original weight, so older events are ignored altogether.
+const trendingWindow = 7
+
+// trendingSize is the number of snippets shown on the trending page.
+const trendingSize = 20
+
+// recomputeTrending rescores the trending snippets straight away and then
+// every interval. It is meant to be run in its own goroutine for the lifetime
+// of the application.
+func (app *application) recomputeTrending(interval, halfLife time.Duration) {
+	app.rescoreTrending(halfLife)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.rescoreTrending(halfLife)
+	}
+}
+
+// rescoreTrending runs a single round of scoring. A panic is recovered and
+// logged so that it doesn't bring down the whole application.
+func (app *application) rescoreTrending(halfLife time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.errorLog.Printf("trending: %s", err)
+		}
+	}()
+
+	err := app.trending.Recompute(halfLife, trendingWindow*halfLife)
+	if err != nil {
+		app.errorLog.Printf("trending: %s", err)
+	}
+}
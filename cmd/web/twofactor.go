@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/qrcode"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/totp"
+)
+
+const (
+	// totpIssuer is the name authenticator apps show next to the account.
+	totpIssuer = "Snippetbox"
+	// recoveryCodeCount is how many recovery codes a user gets when they
+	// enable two-factor authentication.
+	recoveryCodeCount = 10
+	// twoFactorLoginTTL is how long a user has to enter their code after
+	// giving their password, and twoFactorMaxAttempts how many wrong codes
+	// they may enter before having to give their password again.
+	twoFactorLoginTTL    = 5 * time.Minute
+	twoFactorMaxAttempts = 5
+)
+
+// recoveryCodeAlphabet leaves out characters which are easily confused with
+// each other, such as 0 and o or 1 and l.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCodes returns recoveryCodeCount random recovery codes, formatted
+// like "abcde-fghjk", along with their hashes for storage.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The alphabet has 31 characters, so the modulo is very slightly
+		// biased; with 10 characters a code still has about 49 bits.
+		for j := range b {
+			b[j] = recoveryCodeAlphabet[int(b[j])%len(recoveryCodeAlphabet)]
+		}
+
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+		hashes[i] = hashToken(normaliseRecoveryCode(codes[i]))
+	}
+
+	return codes, hashes, nil
+}
+
+// normaliseRecoveryCode lowercases a recovery code and removes the dash and
+// any spaces, so it matches however the user types it.
+func normaliseRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// isTOTPCode reports whether code looks like a code from an authenticator
+// app rather than a recovery code.
+func isTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTOTPSecret returns the base32 secret in groups of four characters,
+// to make it easier to type into an authenticator app by hand.
+func formatTOTPSecret(secret []byte) string {
+	encoded := totp.EncodeSecret(secret)
+
+	var groups []string
+	for len(encoded) > 4 {
+		groups = append(groups, encoded[:4])
+		encoded = encoded[4:]
+	}
+	return strings.Join(append(groups, encoded), " ")
+}
+
+// totpQRCode renders the otpauth URI for a secret as an inline SVG QR code.
+// The SVG is generated entirely by the qrcode package, so it is safe to
+// include in the page unescaped.
+func totpQRCode(account string, secret []byte) (template.HTML, error) {
+	code, err := qrcode.Encode([]byte(totp.URI(totpIssuer, account, secret)))
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(code.SVG(4)), nil
+}
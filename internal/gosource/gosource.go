@@ -0,0 +1,134 @@
+package gosource
+
+import (
+	"errors"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+	"time"
+)
+
+// MaxSize is the largest source, in bytes, that Format and Check will accept.
+// Together with the timeout it bounds the work done for a single request.
+const MaxSize = 256 << 10
+
+var (
+	ErrTooLarge = errors.New("gosource: source is too large")
+	ErrTimeout  = errors.New("gosource: timed out")
+)
+
+// SyntaxError describes a single syntax error, positioned by the line and
+// column in the original snippet.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+// Format formats src with go/format. Like gofmt, it accepts a complete source
+// file as well as a list of declarations or statements.
+//
+// The standard library formatter can't be cancelled, so Format runs it in a
+// separate goroutine and stops waiting for it after timeout. MaxSize keeps the
+// work an abandoned goroutine can do small.
+func Format(src string, timeout time.Duration) (string, error) {
+	if len(src) > MaxSize {
+		return "", ErrTooLarge
+	}
+
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		out, err := format.Source([]byte(src))
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		return string(res.out), res.err
+	case <-time.After(timeout):
+		return "", ErrTimeout
+	}
+}
+
+// Check parses src and returns its syntax errors, if any. As with Format, src
+// may be a complete file or a fragment made up of declarations or statements,
+// and the same size cap and timeout apply.
+func Check(src string, timeout time.Duration) ([]SyntaxError, error) {
+	if len(src) > MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	done := make(chan []SyntaxError, 1)
+
+	go func() {
+		done <- check(src)
+	}()
+
+	select {
+	case errs := <-done:
+		return errs, nil
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	}
+}
+
+// check tries to parse src as a file, then as a list of declarations and
+// finally as a list of statements, in the same way that go/format does. The
+// wrapping prefixes are added on the first line, so line numbers in errors
+// don't need adjusting; columns on the first line do.
+func check(src string) []SyntaxError {
+	err := parse("", src)
+	if err == nil {
+		return nil
+	}
+	if !strings.Contains(err.Error(), "expected 'package'") {
+		return syntaxErrors(err, 0)
+	}
+
+	const declPrefix = "package p;"
+	declErr := parse(declPrefix, src)
+	if declErr == nil {
+		return nil
+	}
+	if !strings.Contains(declErr.Error(), "expected declaration") {
+		return syntaxErrors(declErr, len(declPrefix))
+	}
+
+	const stmtPrefix = "package p; func _() {"
+	stmtErr := parse(stmtPrefix, src+"\n}")
+	if stmtErr == nil {
+		return nil
+	}
+	return syntaxErrors(stmtErr, len(stmtPrefix))
+}
+
+func parse(prefix, src string) error {
+	_, err := parser.ParseFile(token.NewFileSet(), "", prefix+src, parser.AllErrors)
+	return err
+}
+
+// syntaxErrors converts a parser error into SyntaxErrors, removing the width
+// of any wrapping prefix from columns on the first line.
+func syntaxErrors(err error, prefixLen int) []SyntaxError {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return []SyntaxError{{Line: 1, Column: 1, Message: err.Error()}}
+	}
+
+	errs := make([]SyntaxError, 0, len(list))
+	for _, e := range list {
+		column := e.Pos.Column
+		if e.Pos.Line == 1 {
+			column -= prefixLen
+		}
+		errs = append(errs, SyntaxError{Line: e.Pos.Line, Column: column, Message: e.Msg})
+	}
+	return errs
+}
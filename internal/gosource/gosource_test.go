@@ -0,0 +1,90 @@
+package gosource
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "File",
+			src:  "package main\nfunc main(){fmt.Println( \"hi\" )}",
+			want: "package main\n\nfunc main() { fmt.Println(\"hi\") }\n",
+		},
+		{
+			name: "Declarations",
+			src:  "type T struct{A int\nBB string}",
+			want: "type T struct {\n\tA  int\n\tBB string\n}",
+		},
+		{
+			name: "Statements",
+			src:  "x:=1\nif x>0{return}",
+			want: "x := 1\nif x > 0 {\n\treturn\n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.src, time.Second)
+			assert.NilError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestFormatTooLarge(t *testing.T) {
+	_, err := Format(strings.Repeat("x", MaxSize+1), time.Second)
+	assert.Equal(t, err, ErrTooLarge)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		src        string
+		wantLine   int
+		wantColumn int
+	}{
+		{
+			name: "Valid file",
+			src:  "package main\n\nfunc main() {}\n",
+		},
+		{
+			name: "Valid statements",
+			src:  "x := 1\nfmt.Println(x)",
+		},
+		{
+			name:       "Missing brace in file",
+			src:        "package main\n\nfunc main() {\n\tfmt.Println(\"hi\"\n}\n",
+			wantLine:   4,
+			wantColumn: 18,
+		},
+		{
+			name:       "Broken statement on first line",
+			src:        "x := := 1",
+			wantLine:   1,
+			wantColumn: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := Check(tt.src, time.Second)
+			assert.NilError(t, err)
+			if tt.wantLine == 0 {
+				assert.Equal(t, len(errs), 0)
+				return
+			}
+			if len(errs) == 0 {
+				t.Fatal("expected syntax errors")
+			}
+			assert.Equal(t, errs[0].Line, tt.wantLine)
+			assert.Equal(t, errs[0].Column, tt.wantColumn)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Package keyring encrypts and decrypts data with AES-GCM, using a set of
+// named keys so that keys can be rotated. New data is always encrypted with
+// the primary key, while data encrypted with any key in the ring can still be
+// decrypted.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownKey = errors.New("keyring: unknown key ID")
+	ErrDecrypt    = errors.New("keyring: message authentication failed")
+)
+
+// Keyring holds a set of AES keys by ID. It is safe for concurrent use.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// Parse reads a keyring from a comma separated list of "id:key" pairs, where
+// each key is 16, 24 or 32 bytes encoded as standard base64. The first key in
+// the list is the primary key. For example:
+//
+//	2024b:kPH+bIxk5D2deZiIxcaaaA==,2024a:Z1sfQNN3CHC0cEeF4y4R8g==
+func Parse(spec string) (*Keyring, error) {
+	k := &Keyring{aeads: make(map[string]cipher.AEAD)}
+
+	for _, pair := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("keyring: invalid key %q, want id:base64key", pair)
+		}
+		if _, exists := k.aeads[id]; exists {
+			return nil, fmt.Errorf("keyring: duplicate key ID %q", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		if k.primary == "" {
+			k.primary = id
+		}
+		k.aeads[id] = aead
+	}
+
+	return k, nil
+}
+
+// Primary returns the ID of the key used for encryption.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt seals plaintext with the primary key, returning the ID of the key
+// and the ciphertext. The ciphertext is prefixed with a random nonce.
+func (k *Keyring) Encrypt(plaintext []byte) (string, []byte, error) {
+	aead := k.aeads[k.primary]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return k.primary, aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens ciphertext produced by Encrypt with the key of the given ID.
+func (k *Keyring) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
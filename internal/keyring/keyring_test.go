@@ -0,0 +1,75 @@
+package keyring
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+const (
+	oldKey = "old:Z1sfQNN3CHC0cEeF4y4R8g=="
+	newKey = "new:kPH+bIxk5D2deZiIxcaaaAcXCwR3b0Kz1k6Jm8cGDYs="
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		wantPrimary string
+		wantErr     bool
+	}{
+		{name: "Single key", spec: oldKey, wantPrimary: "old"},
+		{name: "First key is primary", spec: newKey + "," + oldKey, wantPrimary: "new"},
+		{name: "Spaces", spec: newKey + ", " + oldKey, wantPrimary: "new"},
+		{name: "Empty", spec: "", wantErr: true},
+		{name: "Missing ID", spec: ":Z1sfQNN3CHC0cEeF4y4R8g==", wantErr: true},
+		{name: "Bad base64", spec: "a:not base64", wantErr: true},
+		{name: "Bad key length", spec: "a:c2hvcnQ=", wantErr: true},
+		{name: "Duplicate ID", spec: oldKey + "," + oldKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := Parse(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, k.Primary(), tt.wantPrimary)
+		})
+	}
+}
+
+func TestRoundTripAndRotation(t *testing.T) {
+	before, err := Parse(oldKey)
+	assert.NilError(t, err)
+
+	keyID, ciphertext, err := before.Encrypt([]byte("An old silent pond..."))
+	assert.NilError(t, err)
+	assert.Equal(t, keyID, "old")
+
+	// After rotation the new key is used for encryption, but data sealed
+	// with the old key can still be read.
+	after, err := Parse(newKey + "," + oldKey)
+	assert.NilError(t, err)
+
+	plaintext, err := after.Decrypt(keyID, ciphertext)
+	assert.NilError(t, err)
+	assert.Equal(t, string(plaintext), "An old silent pond...")
+
+	keyID, _, err = after.Encrypt(plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, keyID, "new")
+
+	_, err = after.Decrypt("missing", ciphertext)
+	assert.Equal(t, err, ErrUnknownKey)
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	_, err = after.Decrypt("old", ciphertext)
+	assert.Equal(t, err, ErrDecrypt)
+
+	_, err = after.Decrypt("old", []byte("short"))
+	assert.Equal(t, err, ErrDecrypt)
+}
@@ -0,0 +1,188 @@
+package langdetect
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Language describes one of the languages a snippet can be tagged with.
+// Extension is the file extension used when a snippet is downloaded, and
+// Comment is the prefix of a line comment, or empty if the language has no
+// comments.
+type Language struct {
+	ID        string
+	Name      string
+	Extension string
+	Comment   string
+}
+
+// Languages lists the languages which snippets can be tagged with, in the
+// order they should be offered to users.
+var Languages = []Language{
+	{ID: "go", Name: "Go", Extension: ".go", Comment: "//"},
+	{ID: "python", Name: "Python", Extension: ".py", Comment: "#"},
+	{ID: "shell", Name: "Shell", Extension: ".sh", Comment: "#"},
+	{ID: "sql", Name: "SQL", Extension: ".sql", Comment: "--"},
+	{ID: "json", Name: "JSON", Extension: ".json"},
+	{ID: "yaml", Name: "YAML", Extension: ".yaml", Comment: "#"},
+	{ID: "text", Name: "Plain text", Extension: ".txt"},
+}
+
+// Lookup returns the language with the given ID.
+func Lookup(id string) (Language, bool) {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Name returns the display name of the language with the given ID, or the ID
+// itself if it isn't a known language.
+func Name(id string) string {
+	if l, ok := Lookup(id); ok {
+		return l.Name
+	}
+	return id
+}
+
+// IDs returns the IDs of all known languages.
+func IDs() []string {
+	ids := make([]string, len(Languages))
+	for i, l := range Languages {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+type signature struct {
+	rx     *regexp.Regexp
+	weight float64
+}
+
+// signatures holds the keyword and file-shape patterns for each language. A
+// pattern contributes its weight once per match, up to maxMatches times.
+var signatures = map[string][]signature{
+	"go": {
+		{regexp.MustCompile(`(?m)^package \w+\s*$`), 6},
+		{regexp.MustCompile(`(?m)^import (\(|"[\w./-]+")`), 4},
+		{regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`), 4},
+		{regexp.MustCompile(`(?m)^type \w+ (struct|interface) \{`), 4},
+		{regexp.MustCompile(`\berr != nil\b`), 3},
+		{regexp.MustCompile(`\w+ :=`), 1},
+		{regexp.MustCompile(`\b(fmt|errors|strings|http|os)\.[A-Z]\w*`), 2},
+		{regexp.MustCompile(`\b(go func|defer |chan |<-)`), 2},
+	},
+	"python": {
+		{regexp.MustCompile(`(?m)^\s*def \w+\(.*\)\s*(->.*)?:\s*$`), 4},
+		{regexp.MustCompile(`(?m)^\s*class \w+(\(.*\))?:\s*$`), 4},
+		{regexp.MustCompile(`(?m)^(from [\w.]+ )?import [\w.]+(\s+as \w+)?(, \w+)*\s*$`), 2},
+		{regexp.MustCompile(`\bself\.\w+`), 2},
+		{regexp.MustCompile(`(?m)^\s*(if|elif|for|while|with|try|except)\b.*:\s*$`), 1},
+		{regexp.MustCompile(`\b(print|len|range|open)\(`), 1},
+		{regexp.MustCompile(`\b(None|True|False|elif|lambda)\b`), 1},
+		{regexp.MustCompile(`if __name__ == ['"]__main__['"]`), 6},
+	},
+	"shell": {
+		{regexp.MustCompile(`(?m)^\s*(\$ )?(export|echo|cd|sudo|apt-get|apt|yum|brew|curl|wget|chmod|chown|mkdir|rm|ls|grep|cat|source|systemctl|docker|kubectl|git|npm|pip|make) `), 2},
+		{regexp.MustCompile(`(?m)^\s*(if \[\[? |fi\s*$|then\s*$|done\s*$|do\s*$|esac\s*$|for \w+ in )`), 3},
+		{regexp.MustCompile(`\$\{?[A-Z_][A-Z0-9_]*\}?`), 1},
+		{regexp.MustCompile(`(?m)^\s*[A-Z_][A-Z0-9_]*=\S*\s*$`), 1},
+		{regexp.MustCompile(`(?m)^\s*\$ \w+`), 2},
+		{regexp.MustCompile(` \|\| | \| \w+|2>&1|>/dev/null`), 2},
+	},
+	"sql": {
+		{regexp.MustCompile(`(?is)\bSELECT\b.+?\bFROM\b`), 3},
+		{regexp.MustCompile(`(?i)\b(INSERT\s+INTO|UPDATE\s+\w+\s+SET|DELETE\s+FROM|CREATE\s+(TABLE|INDEX|VIEW|DATABASE|USER)|ALTER\s+TABLE|DROP\s+(TABLE|INDEX)|GRANT\s+\w+)\b`), 4},
+		{regexp.MustCompile(`(?i)\b(WHERE|INNER JOIN|LEFT JOIN|GROUP BY|ORDER BY|VALUES|PRIMARY KEY|NOT NULL|VARCHAR|INTEGER|DATETIME)\b`), 1},
+		{regexp.MustCompile(`(?m);\s*$`), 0.5},
+	},
+	"yaml": {
+		{regexp.MustCompile(`(?m)^---\s*$`), 3},
+		{regexp.MustCompile(`(?m)^[ \t]*[\w.-]+:[ \t]+[^,{}()\s][^,{}()\n]*$`), 1},
+		{regexp.MustCompile(`(?m)^[ \t]*[\w.-]+:[ \t]*$`), 1.5},
+		{regexp.MustCompile(`(?m)^\s+-\s+[\w"'.-]`), 1},
+		{regexp.MustCompile(`(?m)^\s*#\s`), 0.5},
+	},
+	"json": {
+		{regexp.MustCompile(`"[\w.-]+"\s*:\s*["{\[\dtfn-]`), 1},
+	},
+}
+
+// maxMatches caps how many times a single pattern can contribute to a score,
+// so that one very repetitive line doesn't drown out everything else.
+const maxMatches = 5
+
+// minScore is the smallest winning score treated as a positive detection.
+const minScore = 3
+
+// Detect infers the language of content. It returns the ID of the detected
+// language and a confidence score between 0 and 1. If no language could be
+// detected it returns the empty string and 0.
+//
+// Detection first looks for unambiguous markers -- a shebang line, or content
+// that parses as a JSON object or array -- and otherwise scores every
+// language by the keyword and file-shape signatures that match.
+func Detect(content string) (string, float64) {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return "", 0
+	}
+
+	if lang := detectShebang(trimmed); lang != "" {
+		return lang, 0.95
+	}
+
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json", 0.95
+	}
+
+	scores := make(map[string]float64)
+	var total float64
+	for lang, sigs := range signatures {
+		for _, sig := range sigs {
+			n := len(sig.rx.FindAllStringIndex(content, maxMatches))
+			scores[lang] += float64(n) * sig.weight
+		}
+		total += scores[lang]
+	}
+
+	best, bestScore := "", 0.0
+	for _, l := range Languages {
+		if scores[l.ID] > bestScore {
+			best, bestScore = l.ID, scores[l.ID]
+		}
+	}
+	if bestScore < minScore {
+		return "", 0
+	}
+
+	// Confidence combines how much of the total evidence points at the
+	// winning language with how much evidence there is in absolute terms.
+	share := bestScore / total
+	strength := 1 - math.Exp(-bestScore/8)
+	confidence := math.Round(share*strength*100) / 100
+
+	return best, confidence
+}
+
+var shebangRX = regexp.MustCompile(`^#!\s*(?:\S*/)?(?:env\s+(?:-\S+\s+)*)?(\w+)`)
+
+// detectShebang returns the language named by the interpreter on a leading
+// "#!" line, if it is one we recognise.
+func detectShebang(content string) string {
+	m := shebangRX.FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	switch interpreter := m[1]; {
+	case strings.HasPrefix(interpreter, "python"):
+		return "python"
+	case interpreter == "sh" || interpreter == "bash" || interpreter == "zsh" || interpreter == "dash" || interpreter == "ksh":
+		return "shell"
+	}
+	return ""
+}
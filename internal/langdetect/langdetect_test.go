@@ -0,0 +1,56 @@
+package langdetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestDetect(t *testing.T) {
+	// Each test case reads a sample from the testdata corpus. File extensions
+	// are only there to make the corpus easy to browse; Detect() never sees
+	// them.
+	tests := []struct {
+		file          string
+		want          string
+		minConfidence float64
+	}{
+		{file: "hello.go", want: "go", minConfidence: 0.6},
+		{file: "handler.go.txt", want: "go", minConfidence: 0.5},
+		{file: "model.go.txt", want: "go", minConfidence: 0.4},
+		{file: "fib.py", want: "python", minConfidence: 0.6},
+		{file: "class.py", want: "python", minConfidence: 0.6},
+		{file: "shebang-python", want: "python", minConfidence: 0.9},
+		{file: "deploy.sh", want: "shell", minConfidence: 0.9},
+		{file: "install.txt", want: "shell", minConfidence: 0.5},
+		{file: "prompt.txt", want: "shell", minConfidence: 0.4},
+		{file: "setup.sql", want: "sql", minConfidence: 0.6},
+		{file: "report.sql", want: "sql", minConfidence: 0.5},
+		{file: "compose.yaml", want: "yaml", minConfidence: 0.5},
+		{file: "workflow.yml", want: "yaml", minConfidence: 0.6},
+		{file: "package.json", want: "json", minConfidence: 0.9},
+		{file: "array.json", want: "json", minConfidence: 0.9},
+		{file: "haiku.txt", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lang, confidence := Detect(string(content))
+			assert.Equal(t, lang, tt.want)
+			if confidence < tt.minConfidence || confidence > 1 {
+				t.Errorf("got confidence %.2f; want between %.2f and 1", confidence, tt.minConfidence)
+			}
+		})
+	}
+}
+
+func TestDetectEmpty(t *testing.T) {
+	lang, confidence := Detect("  \n\t")
+	assert.Equal(t, lang, "")
+	assert.Equal(t, confidence, 0.0)
+}
@@ -0,0 +1,15 @@
+import os
+from pathlib import Path
+
+
+class Config:
+    def __init__(self, path):
+        self.path = Path(path)
+        self.values = {}
+
+    def load(self):
+        with open(self.path) as f:
+            for line in f:
+                key, _, value = line.partition("=")
+                self.values[key.strip()] = value.strip()
+        return self.values
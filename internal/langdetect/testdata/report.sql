@@ -0,0 +1,6 @@
+select u.name, count(*) as total
+from users u
+inner join snippets s on s.user_id = u.id
+where s.created > now() - interval 7 day
+group by u.name
+order by total desc;
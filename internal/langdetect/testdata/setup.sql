@@ -0,0 +1,7 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
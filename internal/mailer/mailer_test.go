@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	b, err := format("Snippetbox <no-reply@example.com>", Message{
+		To:      "alice@example.com",
+		Subject: "Réinitialiser",
+		Body:    "Hello Alice,\nan old silent pond...",
+	}, date)
+	assert.NilError(t, err)
+
+	want := "From: Snippetbox <no-reply@example.com>\r\n" +
+		"To: alice@example.com\r\n" +
+		"Subject: =?utf-8?q?R=C3=A9initialiser?=\r\n" +
+		"Date: Sun, 17 Mar 2024 10:15:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Hello Alice,\r\nan old silent pond..."
+	assert.Equal(t, string(b), want)
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{name: "Recipient", msg: Message{To: "alice@example.com\r\nBcc: eve@example.com"}},
+		{name: "Subject", msg: Message{To: "alice@example.com", Subject: "Hi\nBcc: eve@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := format("no-reply@example.com", tt.msg, time.Now())
+			assert.Equal(t, err, ErrInvalidHeader)
+
+			err = (&Log{Logger: log.New(&bytes.Buffer{}, "", 0)}).Send(tt.msg)
+			assert.Equal(t, err, ErrInvalidHeader)
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	m := &Log{Logger: log.New(&buf, "", 0)}
+
+	err := m.Send(Message{To: "alice@example.com", Subject: "Hello", Body: "An old silent pond..."})
+	assert.NilError(t, err)
+	assert.Equal(t, buf.String(), "email to alice@example.com\nSubject: Hello\n\nAn old silent pond...\n")
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/go-sql-driver/mysql"
+)
+
+type CollectionModelInterface interface {
+	Insert(userID int, name, slug, description string) (int, error)
+	Get(id int) (*Collection, error)
+	GetBySlug(slug string) (*Collection, error)
+	ForUser(userID int) ([]*Collection, error)
+	Update(id int, name, description string) error
+	Delete(id int) error
+	Snippets(collectionID int) ([]*Snippet, error)
+	EditableSnippets(collectionID, ownerID int) ([]*Snippet, error)
+	AddSnippet(collectionID, snippetID int) error
+	RemoveSnippet(collectionID, snippetID int) error
+	MoveSnippet(collectionID, snippetID int, up bool) error
+}
+
+// Collection is a named, ordered group of snippets curated by a user. The
+// snippets in a collection may belong to any author.
+type Collection struct {
+	ID          int
+	UserID      int
+	Name        string
+	Slug        string
+	Description string
+	Created     time.Time
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+	// Keys decrypts the content of snippets, as in SnippetModel.
+	Keys *keyring.Keyring
+}
+
+// Insert creates a new collection owned by userID. Slugs are unique across all
+// collections, and ErrDuplicateSlug is returned if the slug is already taken.
+func (m *CollectionModel) Insert(userID int, name, slug, description string) (int, error) {
+	stmt := `INSERT INTO collections (user_id, name, slug, description, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, userID, name, slug, description)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "collections_uc_slug") {
+				return 0, ErrDuplicateSlug
+			}
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (m *CollectionModel) get(query string, arg any) (*Collection, error) {
+	c := &Collection{}
+
+	err := m.DB.QueryRow(query, arg).Scan(&c.ID, &c.UserID, &c.Name, &c.Slug, &c.Description, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (m *CollectionModel) Get(id int) (*Collection, error) {
+	return m.get(`SELECT id, user_id, name, slug, description, created FROM collections WHERE id = ?`, id)
+}
+
+func (m *CollectionModel) GetBySlug(slug string) (*Collection, error) {
+	return m.get(`SELECT id, user_id, name, slug, description, created FROM collections WHERE slug = ?`, slug)
+}
+
+// ForUser returns the collections owned by userID, most recently created first.
+func (m *CollectionModel) ForUser(userID int) ([]*Collection, error) {
+	query := `SELECT id, user_id, name, slug, description, created FROM collections
+	WHERE user_id = ? ORDER BY created DESC`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		c := &Collection{}
+		err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Slug, &c.Description, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Update renames a collection and replaces its description. The slug is left
+// unchanged so that links to the collection keep working.
+func (m *CollectionModel) Update(id int, name, description string) error {
+	stmt := `UPDATE collections SET name = ?, description = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, name, description, id)
+	return err
+}
+
+// Delete removes a collection along with its list of snippets. The snippets
+// themselves are not affected.
+func (m *CollectionModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM collection_snippets WHERE collection_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM collections WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Snippets returns the live, published snippets in a collection in their
+// curated order. Snippets which have expired since being added are left out.
+func (m *CollectionModel) Snippets(collectionID int) ([]*Snippet, error) {
+	// The snippet columns don't clash with any in collection_snippets, so
+	// snippetColumns can be used unqualified.
+	query := `SELECT ` + snippetColumns + ` FROM collection_snippets
+	INNER JOIN snippets ON snippets.id = collection_snippets.snippet_id
+	WHERE collection_snippets.collection_id = ? AND snippets.expires > UTC_TIMESTAMP()
+	AND snippets.published = TRUE
+	ORDER BY collection_snippets.position`
+
+	return m.querySnippets(query, collectionID)
+}
+
+// EditableSnippets returns the live snippets in a collection in their
+// curated order, as its owner sees them while editing it: their own
+// scheduled snippets are included, so that they can be moved or removed
+// before they are published.
+func (m *CollectionModel) EditableSnippets(collectionID, ownerID int) ([]*Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM collection_snippets
+	INNER JOIN snippets ON snippets.id = collection_snippets.snippet_id
+	WHERE collection_snippets.collection_id = ? AND snippets.expires > UTC_TIMESTAMP()
+	AND (snippets.published = TRUE OR snippets.user_id = ?)
+	ORDER BY collection_snippets.position`
+
+	return m.querySnippets(query, collectionID, ownerID)
+}
+
+func (m *CollectionModel) querySnippets(query string, args ...any) ([]*Snippet, error) {
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// AddSnippet appends a snippet to the end of a collection. It returns
+// ErrDuplicateSnippet if the snippet is already in the collection.
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	stmt := `INSERT INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, snippetID, collectionID)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 {
+			return ErrDuplicateSnippet
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, snippetID)
+	return err
+}
+
+// MoveSnippet swaps a snippet with its neighbour in the collection, moving it
+// one place up (towards the start) or down. Moving the first snippet up, or
+// the last snippet down, does nothing.
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow(`SELECT position FROM collection_snippets
+	WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`, collectionID, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	query := `SELECT snippet_id, position FROM collection_snippets
+	WHERE collection_id = ? AND position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if up {
+		query = `SELECT snippet_id, position FROM collection_snippets
+		WHERE collection_id = ? AND position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	var neighbourID, neighbourPosition int
+	err = tx.QueryRow(query, collectionID, position).Scan(&neighbourID, &neighbourPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	stmt := `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+
+	_, err = tx.Exec(stmt, neighbourPosition, collectionID, snippetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(stmt, position, collectionID, neighbourID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -6,4 +6,10 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrDuplicateSlug      = errors.New("models: duplicate slug")
+	ErrDuplicateSnippet   = errors.New("models: snippet already in collection")
+	ErrOverQuota          = errors.New("models: over quota")
+	// ErrNoKeys is returned when storing a TOTP secret without encryption
+	// keys, since secrets are never stored in plain text.
+	ErrNoKeys = errors.New("models: no encryption keys for TOTP secrets")
 )
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/go-sql-driver/mysql"
+)
+
+type FeaturedModelInterface interface {
+	All() ([]*Snippet, error)
+	Count() (int, error)
+	Pin(snippetID int) error
+	Unpin(snippetID int) error
+	Move(snippetID int, up bool) error
+}
+
+// FeaturedModel stores the snippets pinned by admins to the "Featured" section
+// of the home page, along with their order.
+type FeaturedModel struct {
+	DB *sql.DB
+	// Keys decrypts the content of snippets, as in SnippetModel.
+	Keys *keyring.Keyring
+}
+
+// All returns the featured snippets in order. Pinned snippets which have
+// expired, or which aren't published yet, are left out.
+func (m *FeaturedModel) All() ([]*Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM featured_snippets
+	INNER JOIN snippets ON snippets.id = featured_snippets.snippet_id
+	WHERE snippets.expires > UTC_TIMESTAMP() AND snippets.published = TRUE
+	ORDER BY featured_snippets.position`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Count returns the number of featured snippets, leaving out the same ones as
+// All, so that pins which admins can no longer see don't count towards the
+// limit.
+func (m *FeaturedModel) Count() (int, error) {
+	query := `SELECT COUNT(*) FROM featured_snippets
+	INNER JOIN snippets ON snippets.id = featured_snippets.snippet_id
+	WHERE snippets.expires > UTC_TIMESTAMP() AND snippets.published = TRUE`
+
+	var n int
+	err := m.DB.QueryRow(query).Scan(&n)
+	return n, err
+}
+
+// Pin adds a snippet to the end of the featured list, first clearing out the
+// pins of any snippets which have expired, since they will never be shown
+// again. It returns ErrDuplicateSnippet if the snippet is already featured.
+func (m *FeaturedModel) Pin(snippetID int) error {
+	stmt := `DELETE featured_snippets FROM featured_snippets
+	INNER JOIN snippets ON snippets.id = featured_snippets.snippet_id
+	WHERE snippets.expires <= UTC_TIMESTAMP()`
+
+	_, err := m.DB.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO featured_snippets (snippet_id, position, pinned)
+	SELECT ?, COALESCE(MAX(position), 0) + 1, UTC_TIMESTAMP() FROM featured_snippets`
+
+	_, err = m.DB.Exec(stmt, snippetID)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) && mySQLError.Number == 1062 {
+			return ErrDuplicateSnippet
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *FeaturedModel) Unpin(snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM featured_snippets WHERE snippet_id = ?`, snippetID)
+	return err
+}
+
+// Move swaps a featured snippet with its neighbour, moving it one place up or
+// down the list. Moving the first snippet up, or the last down, does nothing.
+func (m *FeaturedModel) Move(snippetID int, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow(`SELECT position FROM featured_snippets WHERE snippet_id = ? FOR UPDATE`, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	query := `SELECT snippet_id, position FROM featured_snippets
+	WHERE position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if up {
+		query = `SELECT snippet_id, position FROM featured_snippets
+		WHERE position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	var neighbourID, neighbourPosition int
+	err = tx.QueryRow(query, position).Scan(&neighbourID, &neighbourPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	stmt := `UPDATE featured_snippets SET position = ? WHERE snippet_id = ?`
+
+	_, err = tx.Exec(stmt, neighbourPosition, snippetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(stmt, position, neighbourID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestFeaturedModelExpiredPins(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	snippets := SnippetModel{DB: db}
+	m := FeaturedModel{DB: db}
+
+	first, err := snippets.Insert(&Snippet{UserID: 1, Title: "Haiku", Content: "An old silent pond..."}, 7, nil)
+	assert.NilError(t, err)
+	second, err := snippets.Insert(&Snippet{UserID: 1, Title: "Haiku", Content: "Over the wintry forest..."}, 7, nil)
+	assert.NilError(t, err)
+
+	err = m.Pin(first)
+	assert.NilError(t, err)
+
+	n, err := m.Count()
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// Once the pinned snippet expires it no longer counts towards the limit,
+	// and its pin is cleared out when the next snippet is pinned.
+	_, err = db.Exec(`UPDATE snippets SET expires = DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 DAY) WHERE id = ?`, first)
+	assert.NilError(t, err)
+
+	n, err = m.Count()
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	err = m.Pin(second)
+	assert.NilError(t, err)
+
+	featured, err := m.All()
+	assert.NilError(t, err)
+	assert.Equal(t, len(featured), 1)
+	assert.Equal(t, featured[0].ID, second)
+
+	var pins int
+	err = db.QueryRow(`SELECT COUNT(*) FROM featured_snippets`).Scan(&pins)
+	assert.NilError(t, err)
+	assert.Equal(t, pins, 1)
+}
@@ -0,0 +1,76 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+var mockCollection = &models.Collection{
+	ID:          1,
+	UserID:      1,
+	Name:        "Onboarding",
+	Slug:        "onboarding",
+	Description: "Snippets for new starters",
+	Created:     time.Now(),
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(userID int, name, slug, description string) (int, error) {
+	switch slug {
+	case mockCollection.Slug:
+		return 0, models.ErrDuplicateSlug
+	default:
+		return 2, nil
+	}
+}
+func (m *CollectionModel) Get(id int) (*models.Collection, error) {
+	switch id {
+	case mockCollection.ID:
+		return mockCollection, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+func (m *CollectionModel) GetBySlug(slug string) (*models.Collection, error) {
+	switch slug {
+	case mockCollection.Slug:
+		return mockCollection, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+func (m *CollectionModel) ForUser(userID int) ([]*models.Collection, error) {
+	if userID == mockCollection.UserID {
+		return []*models.Collection{mockCollection}, nil
+	}
+	return []*models.Collection{}, nil
+}
+func (m *CollectionModel) Update(id int, name, description string) error {
+	return nil
+}
+func (m *CollectionModel) Delete(id int) error {
+	return nil
+}
+func (m *CollectionModel) Snippets(collectionID int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+func (m *CollectionModel) EditableSnippets(collectionID, ownerID int) ([]*models.Snippet, error) {
+	if ownerID == mockScheduledSnippet.UserID {
+		return []*models.Snippet{mockSnippet, mockScheduledSnippet}, nil
+	}
+	return []*models.Snippet{mockSnippet}, nil
+}
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	if snippetID == mockSnippet.ID {
+		return models.ErrDuplicateSnippet
+	}
+	return nil
+}
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	return nil
+}
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import "github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+
+type FeaturedModel struct{}
+
+func (m *FeaturedModel) All() ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+func (m *FeaturedModel) Count() (int, error) {
+	return 1, nil
+}
+func (m *FeaturedModel) Pin(snippetID int) error {
+	if snippetID == mockSnippet.ID {
+		return models.ErrDuplicateSnippet
+	}
+	return nil
+}
+func (m *FeaturedModel) Unpin(snippetID int) error {
+	return nil
+}
+func (m *FeaturedModel) Move(snippetID int, up bool) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import "github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+
+type QuotaModel struct{}
+
+func (m *QuotaModel) Get(userID int) (*models.Quota, error) {
+	return nil, models.ErrNoRecord
+}
+func (m *QuotaModel) Usage(userID int) (*models.Usage, error) {
+	return &models.Usage{Snippets: 2, Bytes: 1200, LastHour: 1}, nil
+}
+func (m *QuotaModel) All() ([]*models.QuotaOverride, error) {
+	return []*models.QuotaOverride{}, nil
+}
+func (m *QuotaModel) Set(email string, quota models.Quota) error {
+	if email == "alice@example.com" {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+func (m *QuotaModel) Remove(userID int) error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// RememberTokenModel keeps tokens in memory, so that tests can follow a token
+// through being issued, used and rotated.
+type RememberTokenModel struct {
+	Tokens map[string]*models.RememberToken
+}
+
+func (m *RememberTokenModel) Insert(selector, validatorHash string, userID int, ttl time.Duration) error {
+	if m.Tokens == nil {
+		m.Tokens = make(map[string]*models.RememberToken)
+	}
+	m.Tokens[selector] = &models.RememberToken{
+		Selector:      selector,
+		ValidatorHash: validatorHash,
+		UserID:        userID,
+		Expiry:        time.Now().Add(ttl),
+	}
+	return nil
+}
+func (m *RememberTokenModel) Claim(selector string) (*models.RememberToken, error) {
+	t, ok := m.Tokens[selector]
+	if !ok || time.Now().After(t.Expiry) {
+		return nil, models.ErrNoRecord
+	}
+	delete(m.Tokens, selector)
+	return t, nil
+}
+func (m *RememberTokenModel) Delete(selector string) error {
+	delete(m.Tokens, selector)
+	return nil
+}
+func (m *RememberTokenModel) DeleteAllForUser(userID int) error {
+	for selector, t := range m.Tokens {
+		if t.UserID == userID {
+			delete(m.Tokens, selector)
+		}
+	}
+	return nil
+}
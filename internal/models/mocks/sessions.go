@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// SessionModel lists two sessions for each user: 1, the current one, and 2,
+// on another device. The most recent session added with a remember me token
+// stands in for session 2, so that revoking it deletes the token from
+// RememberTokens, if set, as the real model does. Touches counts the calls
+// to Touch.
+type SessionModel struct {
+	RememberTokens   *RememberTokenModel
+	RememberSelector string
+	Touches          int
+}
+
+func (m *SessionModel) Add(token string, userID int, userAgent, ip, rememberSelector string) error {
+	if rememberSelector != "" {
+		m.RememberSelector = rememberSelector
+	}
+	return nil
+}
+func (m *SessionModel) Remove(token string) error {
+	return nil
+}
+func (m *SessionModel) Touch(token string) error {
+	m.Touches++
+	return nil
+}
+func (m *SessionModel) Count(userID int) (int, error) {
+	return 2, nil
+}
+func (m *SessionModel) List(userID int, current string) ([]*models.Session, error) {
+	return []*models.Session{
+		{
+			ID:        1,
+			UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			IP:        "192.0.2.1",
+			Created:   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			LastSeen:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Current:   true,
+		},
+		{
+			ID:        2,
+			UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)",
+			IP:        "198.51.100.7",
+			Created:   time.Date(2023, 12, 30, 9, 0, 0, 0, time.UTC),
+			LastSeen:  time.Date(2023, 12, 31, 18, 0, 0, 0, time.UTC),
+		},
+	}, nil
+}
+func (m *SessionModel) Revoke(userID, id int) error {
+	switch id {
+	case 1:
+		return nil
+	case 2:
+		if m.RememberTokens != nil && m.RememberSelector != "" {
+			return m.RememberTokens.Delete(m.RememberSelector)
+		}
+		return nil
+	}
+	return models.ErrNoRecord
+}
+func (m *SessionModel) RevokeAll(userID int, except string) error {
+	return nil
+}
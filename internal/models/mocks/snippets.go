@@ -7,22 +7,60 @@ import (
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:                 1,
+	UserID:             1,
+	Kind:               models.KindPlain,
+	Title:              "An old silent pond",
+	Content:            "An old silent pond...",
+	ContentHash:        models.ContentHash("An old silent pond..."),
+	Language:           "text",
+	LanguageConfidence: 0.5,
+	Published:          true,
+	License:            "CC-BY-4.0",
+	SourceURL:          "https://example.com/haiku",
+	Attribution:        "Matsuo Bashō",
+	Created:            time.Now(),
+	Expires:            time.Now(),
+}
+
+var mockScheduledSnippet = &models.Snippet{
+	ID:          3,
+	UserID:      1,
+	Kind:        models.KindPlain,
+	Title:       "Over the wintry forest",
+	Content:     "Over the wintry forest...",
+	ContentHash: models.ContentHash("Over the wintry forest..."),
+	Published:   false,
+	PublishAt:   time.Now().Add(time.Hour),
+	Created:     time.Now(),
+	Expires:     time.Now(),
+}
+
+var mockSecretSnippet = &models.Snippet{
+	ID:          4,
+	UserID:      1,
+	Kind:        models.KindSecret,
+	Title:       "Encrypted snippet",
+	Content:     "v1.AAECAwQFBgcICQoL.3q2-7_7u_wABAgMEBQYHCAkKCww",
+	ContentHash: models.ContentHash("v1.AAECAwQFBgcICQoL.3q2-7_7u_wABAgMEBQYHCAkKCww"),
+	Published:   true,
+	Created:     time.Now(),
+	Expires:     time.Now(),
 }
 
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+func (m *SnippetModel) Insert(snippet *models.Snippet, expires int, quota *models.Quota) (int, error) {
 	return 2, nil
 }
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockScheduledSnippet, nil
+	case 4:
+		return mockSecretSnippet, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -30,3 +68,31 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+func (m *SnippetModel) AllPublished() ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
+func (m *SnippetModel) FindDuplicate(userID int, contentHash string) (*models.Snippet, error) {
+	if userID == mockSnippet.UserID && contentHash == mockSnippet.ContentHash {
+		return mockSnippet, nil
+	}
+	return nil, models.ErrNoRecord
+}
+func (m *SnippetModel) SetLanguage(id int, language string) error {
+	return nil
+}
+func (m *SnippetModel) Revise(id int, content string, quota *models.Quota) error {
+	return nil
+}
+func (m *SnippetModel) PublishDue() ([]*models.Snippet, error) {
+	return []*models.Snippet{}, nil
+}
+func (m *SnippetModel) DeleteWithToken(id int, tokenHash string) error {
+	// The hash of "valid-token".
+	if id == 1 && tokenHash == "397a2a9c5bf5e2ccec38c2596b682bb1bd05fe6e4ecea6c10cf42755ff225403" {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+func (m *SnippetModel) Counts(userID int) (*models.SnippetCounts, error) {
+	return &models.SnippetCounts{Live: 2, Scheduled: 1, Expired: 3}, nil
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(tokenHash string, userID int, scope string, ttl time.Duration) error {
+	return nil
+}
+func (m *TokenModel) Consume(tokenHash, scope string) (int, error) {
+	// The hash of "valid-token".
+	if tokenHash == "397a2a9c5bf5e2ccec38c2596b682bb1bd05fe6e4ecea6c10cf42755ff225403" {
+		return 1, nil
+	}
+	return 0, models.ErrNoRecord
+}
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	return nil
+}
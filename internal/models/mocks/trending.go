@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+type TrendingModel struct{}
+
+func (m *TrendingModel) RecordView(snippetID int, viewer string) error {
+	return nil
+}
+func (m *TrendingModel) Star(userID, snippetID int) error {
+	return nil
+}
+func (m *TrendingModel) Unstar(userID, snippetID int) error {
+	return nil
+}
+func (m *TrendingModel) Starred(userID, snippetID int) (bool, error) {
+	return false, nil
+}
+func (m *TrendingModel) Recompute(halfLife, window time.Duration) error {
+	return nil
+}
+func (m *TrendingModel) Top(n int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/totp"
+)
+
+// TOTPSecret is the secret of Dave (ID 3), who has two-factor
+// authentication enabled, and the pending secret of every other user.
+var TOTPSecret = []byte("12345678901234567890")
+
+type TwoFactorModel struct{}
+
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	return userID == 3, nil
+}
+func (m *TwoFactorModel) Secret(userID int) ([]byte, error) {
+	if userID == 3 {
+		return nil, models.ErrNoRecord
+	}
+	return TOTPSecret, nil
+}
+func (m *TwoFactorModel) SetSecret(userID int, secret []byte) error {
+	return nil
+}
+func (m *TwoFactorModel) Enable(userID int, step int64, recoveryCodeHashes []string) error {
+	return nil
+}
+func (m *TwoFactorModel) Disable(userID int) error {
+	return nil
+}
+func (m *TwoFactorModel) Validate(userID int, code string, t time.Time) error {
+	if _, ok := totp.Validate(TOTPSecret, code, t, 1); ok && userID == 3 {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
+func (m *TwoFactorModel) UseRecoveryCode(userID int, codeHash string) error {
+	// The hash of "abcdefghij", the recovery code "abcde-fghij" normalised.
+	if userID == 3 && codeHash == "72399361da6a7754fec986dca5b7cbaf1c810a28ded4abaf56b2106d06cb78b0" {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	return 10, nil
+}
@@ -1,28 +1,86 @@
 package mocks
 
-import "github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+import (
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/models"
+)
+
+// Alice (ID 1) is an activated admin. Carol (ID 2) hasn't verified her email
+// address yet. Dave (ID 3) has two-factor authentication enabled.
+var mockUsers = map[int]*models.User{
+	1: {
+		ID:        1,
+		Name:      "Alice Jones",
+		Email:     "alice@example.com",
+		Created:   time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		Activated: true,
+	},
+	2: {
+		ID:      2,
+		Name:    "Carol Davis",
+		Email:   "carol@example.com",
+		Created: time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC),
+	},
+	3: {
+		ID:        3,
+		Name:      "Dave Smith",
+		Email:     "dave@example.com",
+		Created:   time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC),
+		Activated: true,
+	},
+}
 
 type UserModel struct{}
 
 func (m *UserModel) Insert(name, email, password string) error {
-	switch email {
-	case "dupe@example.com":
+	if _, err := m.GetByEmail(email); err == nil {
 		return models.ErrDuplicateEmail
-	default:
-		return nil
 	}
+	return nil
 }
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
-		return 1, nil
+	user, err := m.GetByEmail(email)
+	if err == nil && password == "pa$$word" {
+		return user.ID, nil
 	}
 	return 0, models.ErrInvalidCredentials
 }
 func (m *UserModel) Exists(id int) (bool, error) {
-	switch id {
-	case 1:
-		return true, nil
-	default:
-		return false, nil
+	_, ok := mockUsers[id]
+	return ok, nil
+}
+func (m *UserModel) Get(id int) (*models.User, error) {
+	user, ok := mockUsers[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return user, nil
+}
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	if id == 1 && currentPassword == "pa$$word" {
+		return nil
+	}
+	return models.ErrInvalidCredentials
+}
+func (m *UserModel) PasswordReset(tokenHash, newPassword string) (int, error) {
+	// The hash of "valid-token".
+	if tokenHash == "397a2a9c5bf5e2ccec38c2596b682bb1bd05fe6e4ecea6c10cf42755ff225403" {
+		return 1, nil
 	}
+	return 0, models.ErrNoRecord
+}
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	for _, user := range mockUsers {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+func (m *UserModel) Activate(id int) error {
+	return nil
+}
+func (m *UserModel) IsAdmin(id int) (bool, error) {
+	return id == 1, nil
 }
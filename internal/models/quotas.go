@@ -0,0 +1,200 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+type QuotaModelInterface interface {
+	Get(userID int) (*Quota, error)
+	Usage(userID int) (*Usage, error)
+	All() ([]*QuotaOverride, error)
+	Set(email string, quota Quota) error
+	Remove(userID int) error
+}
+
+// Quota limits how much a user can store. Snippets is the most live snippets
+// they may have, Bytes the most content in bytes across those snippets, and
+// PerHour the most snippets they may create in an hour. A limit of NoLimit
+// means no limit, while a limit of 0 allows nothing.
+type Quota struct {
+	Snippets int
+	Bytes    int
+	PerHour  int
+}
+
+// NoLimit is the value of a limit in a Quota which doesn't limit anything.
+const NoLimit = -1
+
+// SnippetsFull reports whether the usage has reached the limit on live
+// snippets, so that no more can be created.
+func (q Quota) SnippetsFull(u *Usage) bool {
+	return q.Snippets != NoLimit && u.Snippets >= q.Snippets
+}
+
+// HourFull reports whether the usage has reached the limit on snippets
+// created in an hour.
+func (q Quota) HourFull(u *Usage) bool {
+	return q.PerHour != NoLimit && u.LastHour >= q.PerHour
+}
+
+// TooLarge reports whether adding size bytes to the usage would take it over
+// the storage limit.
+func (q Quota) TooLarge(u *Usage, size int) bool {
+	return q.Bytes != NoLimit && u.Bytes+size > q.Bytes
+}
+
+// Usage is what a user currently has stored, to be compared with their
+// Quota. LastHour counts the snippets they created in the past hour,
+// including any which have since expired.
+type Usage struct {
+	Snippets int
+	Bytes    int
+	LastHour int
+}
+
+// QuotaOverride is a user's own quota, set by an admin in place of the
+// default limits.
+type QuotaOverride struct {
+	UserID int
+	Name   string
+	Email  string
+	Quota
+}
+
+// QuotaModel stores per-user quota overrides and reports users' usage. Users
+// without an override get the default limits, which are configured in the
+// web application rather than stored here.
+type QuotaModel struct {
+	DB *sql.DB
+}
+
+// Get returns the user's quota override, or ErrNoRecord if they use the
+// defaults.
+func (m *QuotaModel) Get(userID int) (*Quota, error) {
+	q := &Quota{}
+
+	stmt := `SELECT max_snippets, max_bytes, max_per_hour FROM user_quotas WHERE user_id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&q.Snippets, &q.Bytes, &q.PerHour)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Usage counts the user's live snippets, scheduled ones included, and their
+// total size. Sizes are the length of the plain text, so they don't depend on
+// whether content is encrypted at rest.
+func (m *QuotaModel) Usage(userID int) (*Usage, error) {
+	return usage(m.DB.QueryRow(usageQuery, userID))
+}
+
+const usageQuery = `SELECT
+	COUNT(CASE WHEN expires > UTC_TIMESTAMP() THEN 1 END),
+	COALESCE(SUM(CASE WHEN expires > UTC_TIMESTAMP() THEN size END), 0),
+	COUNT(CASE WHEN created > UTC_TIMESTAMP() - INTERVAL 1 HOUR THEN 1 END)
+FROM snippets WHERE user_id = ?`
+
+// usage scans the result of usageQuery, which may be run inside a transaction
+// as well as on its own.
+func usage(row *sql.Row) (*Usage, error) {
+	u := &Usage{}
+
+	err := row.Scan(&u.Snippets, &u.Bytes, &u.LastHour)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// lockUsage locks the user's row until the end of the transaction and then
+// returns their usage. Concurrent changes to the same user's snippets wait
+// for the lock, so a quota checked against the usage still holds when the
+// change is made. Only locking reads may come before it in the transaction,
+// since a plain read would fix the snapshot the usage is read from before
+// the lock was taken.
+func lockUsage(tx *sql.Tx, userID int) (*Usage, error) {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage(tx.QueryRow(usageQuery, userID))
+}
+
+// All returns every quota override, ordered by the users' email addresses.
+func (m *QuotaModel) All() ([]*QuotaOverride, error) {
+	stmt := `SELECT users.id, users.name, users.email, max_snippets, max_bytes, max_per_hour
+	FROM user_quotas INNER JOIN users ON users.id = user_quotas.user_id
+	ORDER BY users.email`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []*QuotaOverride{}
+
+	for rows.Next() {
+		o := &QuotaOverride{}
+		err = rows.Scan(&o.UserID, &o.Name, &o.Email, &o.Snippets, &o.Bytes, &o.PerHour)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// Set creates or replaces the quota override of the user with the given
+// email address. It returns ErrNoRecord if there is no such user.
+func (m *QuotaModel) Set(email string, quota Quota) error {
+	stmt := `INSERT INTO user_quotas (user_id, max_snippets, max_bytes, max_per_hour)
+	SELECT id, ?, ?, ? FROM users WHERE email = ?
+	ON DUPLICATE KEY UPDATE max_snippets = VALUES(max_snippets), max_bytes = VALUES(max_bytes),
+		max_per_hour = VALUES(max_per_hour)`
+
+	result, err := m.DB.Exec(stmt, quota.Snippets, quota.Bytes, quota.PerHour, email)
+	if err != nil {
+		return err
+	}
+
+	// Updating a row with identical values affects no rows, so check that
+	// the user exists before reporting that there isn't one.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		var exists bool
+		err = m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM users WHERE email = ?)`, email).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNoRecord
+		}
+	}
+
+	return nil
+}
+
+// Remove deletes the user's quota override, so that they get the default
+// limits again.
+func (m *QuotaModel) Remove(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM user_quotas WHERE user_id = ?`, userID)
+	return err
+}
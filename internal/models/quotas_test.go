@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestSnippetModelQuota(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := SnippetModel{DB: newTestDB(t)}
+
+	// A limit of 0 allows nothing, unlike NoLimit.
+	none := &Quota{Snippets: 0, Bytes: NoLimit, PerHour: NoLimit}
+	_, err := m.Insert(&Snippet{UserID: 1, Title: "Haiku", Content: "An old silent pond..."}, 7, none)
+	assert.Equal(t, err, ErrOverQuota)
+
+	unlimited := &Quota{Snippets: NoLimit, Bytes: NoLimit, PerHour: NoLimit}
+	id, err := m.Insert(&Snippet{UserID: 1, Title: "Haiku", Content: "An old silent pond..."}, 7, unlimited)
+	assert.NilError(t, err)
+
+	u, err := (&QuotaModel{DB: m.DB}).Usage(1)
+	assert.NilError(t, err)
+
+	// Revisions may shrink a snippet within the storage limit, but not grow
+	// it past the limit.
+	full := &Quota{Snippets: NoLimit, Bytes: u.Bytes, PerHour: NoLimit}
+	err = m.Revise(id, "An old pond...", full)
+	assert.NilError(t, err)
+	err = m.Revise(id, "An old silent pond, a frog jumps into the pond...", full)
+	assert.Equal(t, err, ErrOverQuota)
+
+	s, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Content, "An old pond...")
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type RememberTokenModelInterface interface {
+	Insert(selector, validatorHash string, userID int, ttl time.Duration) error
+	Claim(selector string) (*RememberToken, error)
+	Delete(selector string) error
+	DeleteAllForUser(userID int) error
+}
+
+// RememberToken keeps a user logged in across sessions on one device. The
+// token in the device's cookie has two parts: a selector, which is stored as
+// it is so the token can be looked up, and a validator, of which only the
+// SHA-256 hash is stored. Someone who can read the database can't use the
+// tokens, and looking one up doesn't leak the validator through timing.
+type RememberToken struct {
+	Selector      string
+	ValidatorHash string
+	UserID        int
+	Expiry        time.Time
+}
+
+type RememberTokenModel struct {
+	DB *sql.DB
+}
+
+// Insert stores a token for the user, which expires after ttl.
+func (m *RememberTokenModel) Insert(selector, validatorHash string, userID int, ttl time.Duration) error {
+	stmt := `INSERT INTO remember_tokens (selector, validator_hash, user_id, created, expiry)
+	VALUES (?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err := m.DB.Exec(stmt, selector, validatorHash, userID, int(ttl.Seconds()))
+	return err
+}
+
+// Claim deletes the unexpired token with the given selector and returns it,
+// or returns ErrNoRecord if there isn't one. Each token can only be claimed
+// once, even by concurrent requests, so the caller has it to itself.
+func (m *RememberTokenModel) Claim(selector string) (*RememberToken, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	t := &RememberToken{}
+
+	stmt := `SELECT selector, validator_hash, user_id, expiry FROM remember_tokens
+	WHERE selector = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, selector).Scan(&t.Selector, &t.ValidatorHash, &t.UserID, &t.Expiry)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM remember_tokens WHERE selector = ?`, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n != 1 {
+		return nil, ErrNoRecord
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (m *RememberTokenModel) Delete(selector string) error {
+	_, err := m.DB.Exec(`DELETE FROM remember_tokens WHERE selector = ?`, selector)
+	return err
+}
+
+// DeleteAllForUser deletes every one of the user's tokens, logging them out
+// of every device they chose to be remembered on.
+func (m *RememberTokenModel) DeleteAllForUser(userID int) error {
+	_, err := m.DB.Exec(`DELETE FROM remember_tokens WHERE user_id = ?`, userID)
+	return err
+}
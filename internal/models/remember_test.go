@@ -0,0 +1,45 @@
+package models
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestRememberTokenModelClaim(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := RememberTokenModel{newTestDB(t)}
+
+	err := m.Insert("selector-000001", "hash", 1, time.Hour)
+	assert.NilError(t, err)
+
+	// Concurrent requests with the same cookie can't both claim the token.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := m.Claim("selector-000001")
+			if err == ErrNoRecord {
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, token.UserID, 1)
+
+			mu.Lock()
+			claimed++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, claimed, 1)
+}
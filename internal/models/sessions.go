@@ -0,0 +1,222 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type SessionModelInterface interface {
+	Add(token string, userID int, userAgent, ip, rememberSelector string) error
+	Remove(token string) error
+	Touch(token string) error
+	Count(userID int) (int, error)
+	List(userID int, current string) ([]*Session, error)
+	Revoke(userID, id int) error
+	RevokeAll(userID int, except string) error
+}
+
+// Session describes one of a user's live sessions: the browser and IP
+// address it was logged in from, when, and when it was last used. ID
+// identifies the session without giving away its token. Current is true for
+// the session whose token was passed to List.
+type Session struct {
+	ID        int
+	UserAgent string
+	IP        string
+	Created   time.Time
+	LastSeen  time.Time
+	Current   bool
+}
+
+// maxUserAgent is the longest user agent string which is stored, in bytes.
+const maxUserAgent = 255
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 encoded
+// character, which MySQL would reject.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// SessionModel indexes the sessions in the scs sessions table by the user
+// who is logged in to them. The session data itself is encoded by scs and
+// can't be queried, so a row is added here whenever a user logs in.
+//
+// Rows for sessions which have since expired, or whose token was renewed,
+// are left behind until the user next logs in, so queries only count rows
+// which still have a live session.
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// Add records that userID is logged in to the session with the given token,
+// from a browser with the given user agent and IP address, clearing out any
+// of the user's rows which no longer have a session. If the browser was given
+// a remember me token along with the session, rememberSelector is its
+// selector, so that the token can be deleted when the session is revoked.
+func (m *SessionModel) Add(token string, userID int, userAgent, ip, rememberSelector string) error {
+	stmt := `DELETE FROM user_sessions WHERE user_id = ?
+	AND token NOT IN (SELECT token FROM sessions WHERE expiry > UTC_TIMESTAMP(6))`
+
+	_, err := m.DB.Exec(stmt, userID)
+	if err != nil {
+		return err
+	}
+
+	userAgent = truncate(strings.ToValidUTF8(userAgent, ""), maxUserAgent)
+
+	var selector sql.NullString
+	if rememberSelector != "" {
+		selector = sql.NullString{String: rememberSelector, Valid: true}
+	}
+
+	stmt = `INSERT INTO user_sessions (token, user_id, user_agent, ip, remember_selector, created, last_seen)
+	VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), user_agent = VALUES(user_agent), ip = VALUES(ip),
+		remember_selector = VALUES(remember_selector), created = VALUES(created), last_seen = VALUES(last_seen)`
+
+	_, err = m.DB.Exec(stmt, token, userID, userAgent, ip, selector)
+	return err
+}
+
+// Remove deletes the index row for a session, for example when its user logs
+// out. The session itself is left to scs.
+func (m *SessionModel) Remove(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM user_sessions WHERE token = ?`, token)
+	return err
+}
+
+// Touch records that the session with the given token has just been used.
+// To save a write on every request, the time is only updated if it is more
+// than a minute old.
+func (m *SessionModel) Touch(token string) error {
+	stmt := `UPDATE user_sessions SET last_seen = UTC_TIMESTAMP()
+	WHERE token = ? AND last_seen < UTC_TIMESTAMP() - INTERVAL 1 MINUTE`
+
+	_, err := m.DB.Exec(stmt, token)
+	return err
+}
+
+// Count returns the number of live sessions the user is logged in to.
+func (m *SessionModel) Count(userID int) (int, error) {
+	var n int
+
+	stmt := `SELECT COUNT(*) FROM user_sessions
+	INNER JOIN sessions ON sessions.token = user_sessions.token
+	WHERE user_sessions.user_id = ? AND sessions.expiry > UTC_TIMESTAMP(6)`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&n)
+	return n, err
+}
+
+// List returns the user's live sessions, most recently used first. The
+// session with the token given in current is marked as the current one.
+func (m *SessionModel) List(userID int, current string) ([]*Session, error) {
+	stmt := `SELECT user_sessions.id, user_sessions.user_agent, user_sessions.ip, user_sessions.created,
+		user_sessions.last_seen, user_sessions.token = ?
+	FROM user_sessions INNER JOIN sessions ON sessions.token = user_sessions.token
+	WHERE user_sessions.user_id = ? AND sessions.expiry > UTC_TIMESTAMP(6)
+	ORDER BY user_sessions.last_seen DESC, user_sessions.id DESC`
+
+	rows, err := m.DB.Query(stmt, current, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		s := &Session{}
+		err = rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.Created, &s.LastSeen, &s.Current)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke logs the user out of one of their sessions, deleting it from the
+// scs store as well as from the index, along with any remember me token
+// which was issued with it, so that the device can't log straight back in.
+// It returns ErrNoRecord if the user has no session with the given ID.
+func (m *SessionModel) Revoke(userID, id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var token string
+	var selector sql.NullString
+
+	stmt := `SELECT token, remember_selector FROM user_sessions WHERE id = ? AND user_id = ? FOR UPDATE`
+
+	err = tx.QueryRow(stmt, id, userID).Scan(&token, &selector)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_sessions WHERE token = ?`, token)
+	if err != nil {
+		return err
+	}
+
+	if selector.Valid {
+		_, err = tx.Exec(`DELETE FROM remember_tokens WHERE selector = ?`, selector.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RevokeAll logs the user out of every session except the one with the token
+// given in except, by deleting the sessions from the scs store as well as
+// from the index.
+func (m *SessionModel) RevokeAll(userID int, except string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE sessions FROM sessions
+	INNER JOIN user_sessions ON user_sessions.token = sessions.token
+	WHERE user_sessions.user_id = ? AND user_sessions.token <> ?`
+
+	_, err = tx.Exec(stmt, userID, except)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM user_sessions WHERE user_id = ? AND token <> ?`, userID, except)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
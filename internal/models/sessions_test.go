@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "Short", s: "Mozilla", n: 10, want: "Mozilla"},
+		{name: "ASCII", s: "Mozilla/5.0", n: 7, want: "Mozilla"},
+		{name: "Rune boundary", s: "abcé", n: 5, want: "abcé"},
+		{name: "Mid rune", s: "abcé", n: 4, want: "abc"},
+		{name: "Mid four-byte rune", s: "a😀", n: 3, want: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, truncate(tt.s, tt.n), tt.want)
+		})
+	}
+}
+
+func TestSessionModelRevoke(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := SessionModel{db}
+	remember := RememberTokenModel{db}
+
+	token := strings.Repeat("a", 43)
+
+	_, err := db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES (?, '', DATE_ADD(UTC_TIMESTAMP(6), INTERVAL 1 HOUR))`, token)
+	assert.NilError(t, err)
+
+	err = remember.Insert("selector-000001", "hash", 1, time.Hour)
+	assert.NilError(t, err)
+
+	err = m.Add(token, 1, "Mozilla/5.0", "192.0.2.1", "selector-000001")
+	assert.NilError(t, err)
+
+	sessions, err := m.List(1, token)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 1)
+	assert.Equal(t, sessions[0].Current, true)
+
+	// Other users can't revoke the session.
+	err = m.Revoke(2, sessions[0].ID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	err = m.Revoke(1, sessions[0].ID)
+	assert.NilError(t, err)
+
+	sessions, err = m.List(1, token)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 0)
+
+	// The remember me token issued with the session is gone too.
+	_, err = remember.Claim("selector-000001")
+	assert.Equal(t, err, ErrNoRecord)
+}
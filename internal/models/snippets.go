@@ -1,34 +1,254 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
+	Insert(snippet *Snippet, expires int, quota *Quota) (int, error)
 	Get(id int) (*Snippet, error)
 	Latest() ([]*Snippet, error)
+	AllPublished() ([]*Snippet, error)
+	FindDuplicate(userID int, contentHash string) (*Snippet, error)
+	SetLanguage(id int, language string) error
+	Revise(id int, content string, quota *Quota) error
+	PublishDue() ([]*Snippet, error)
+	DeleteWithToken(id int, tokenHash string) error
+	Counts(userID int) (*SnippetCounts, error)
 }
 
+// The kinds of snippet. The content of a secret snippet is a payload
+// encrypted in the browser, in the format checked by the sealed package, and
+// the server never has the key to read it.
+const (
+	KindPlain  = "plain"
+	KindSecret = "secret"
+)
+
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID          int
+	UserID      int
+	Kind        string
+	Title       string
+	Content     string
+	ContentHash string
+	// Language is the ID of the snippet's language, as listed in
+	// langdetect.Languages. LanguageConfidence is the detector's confidence
+	// score between 0 and 1 if the language was inferred from the content,
+	// or 0 if it was chosen by the user.
+	Language           string
+	LanguageConfidence float64
+	// Published is false for a snippet scheduled to go live at PublishAt.
+	// Until then it is visible only to its owner. PublishAt is the zero time
+	// for snippets which were published immediately.
+	Published bool
+	PublishAt time.Time
+	// ForkedFrom is the ID of the snippet this one was forked from, or 0.
+	ForkedFrom int
+	// License is the SPDX identifier of the snippet's license, and SourceURL
+	// and Attribution say where it came from. All three are optional.
+	License     string
+	SourceURL   string
+	Attribution string
+	// DeleteTokenHash is the SHA-256 hash of the secret token which lets a
+	// guest delete the snippet they created. It is only used by Insert, and
+	// isn't read back.
+	DeleteTokenHash string
+	Created         time.Time
+	Expires         time.Time
+}
+
+// SnippetCounts summarises the snippets owned by a user. Live snippets have
+// been published and haven't expired, scheduled snippets are waiting to be
+// published, and expired snippets are no longer shown to anyone.
+type SnippetCounts struct {
+	Live      int
+	Scheduled int
+	Expired   int
+}
+
+// IsSecret reports whether the snippet was encrypted in the browser.
+func (s *Snippet) IsSecret() bool {
+	return s.Kind == KindSecret
 }
 
+// LanguageDetected reports whether the snippet's language was inferred from
+// its content rather than chosen by the user.
+func (s *Snippet) LanguageDetected() bool {
+	return s.Language != "" && s.LanguageConfidence > 0
+}
+
+// SnippetModel stores snippets in MySQL. If Keys is set, snippet content,
+// including the content of earlier revisions, is encrypted at rest with the
+// keyring's primary key, and the ID of the key is stored alongside it. Rows
+// written without a keyring have an empty key ID and are read back as they
+// are, so encryption can be turned on for an existing database; the rekey
+// command then encrypts the older rows.
+//
+// Titles and other metadata are not encrypted, and nor is the content hash
+// used to find duplicates. The hash doesn't reveal the content, but it does
+// let someone with access to the database check a guess at it.
 type SnippetModel struct {
-	DB *sql.DB
+	DB   *sql.DB
+	Keys *keyring.Keyring
+}
+
+// snippetColumns lists the columns read back by every snippet query, in the
+// order expected by scanSnippet().
+const snippetColumns = `id, user_id, kind, title, content, key_id, content_hash, language, language_confidence,
+	published, publish_at, forked_from, license, source_url, attribution, created, expires`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet reads a row of snippetColumns, decrypting the content with keys
+// if it was stored encrypted.
+func scanSnippet(row scanner, keys *keyring.Keyring) (*Snippet, error) {
+	s := &Snippet{}
+	var keyID string
+	var publishAt sql.NullTime
+	var forkedFrom sql.NullInt64
+	err := row.Scan(&s.ID, &s.UserID, &s.Kind, &s.Title, &s.Content, &keyID, &s.ContentHash, &s.Language, &s.LanguageConfidence,
+		&s.Published, &publishAt, &forkedFrom, &s.License, &s.SourceURL, &s.Attribution, &s.Created, &s.Expires)
+	if err != nil {
+		return nil, err
+	}
+	s.Content, err = openContent(keys, keyID, s.Content)
+	if err != nil {
+		return nil, fmt.Errorf("snippet %d: %w", s.ID, err)
+	}
+	s.PublishAt = publishAt.Time
+	s.ForkedFrom = int(forkedFrom.Int64)
+	return s, nil
+}
+
+// sealContent prepares content for storage. With a keyring it returns the ID
+// of the primary key and the base64 encoded ciphertext; without one it returns
+// an empty key ID and the content unchanged.
+func sealContent(keys *keyring.Keyring, content string) (string, string, error) {
+	if keys == nil {
+		return "", content, nil
+	}
+
+	keyID, ciphertext, err := keys.Encrypt([]byte(content))
+	if err != nil {
+		return "", "", err
+	}
+	return keyID, base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// openContent reverses sealContent. Content with an empty key ID was stored
+// unencrypted.
+func openContent(keys *keyring.Keyring, keyID, stored string) (string, error) {
+	if keyID == "" {
+		return stored, nil
+	}
+	if keys == nil {
+		return "", keyring.ErrUnknownKey
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := keys.Decrypt(keyID, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	query := `INSERT INTO snippets (title, content, created, expires) 
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// ContentHash returns the hex encoded SHA-256 hash of the normalised snippet
+// content. Normalisation converts Windows and old Mac line endings to "\n",
+// strips trailing whitespace from every line and drops leading and trailing
+// blank lines, so that the same text pasted from different editors produces
+// the same hash.
+func ContentHash(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	normalised := strings.Trim(strings.Join(lines, "\n"), "\n")
+
+	sum := sha256.Sum256([]byte(normalised))
+	return hex.EncodeToString(sum[:])
+}
+
+// Insert adds a new snippet owned by snippet.UserID which expires after the
+// given number of days. The normalised content hash is computed here and
+// stored alongside the content so that FindDuplicate() is an indexed lookup,
+// as is the size of the content in bytes, which counts towards the owner's
+// quota.
+//
+// If snippet.PublishAt is set the snippet is stored unpublished, to be made
+// live by PublishDue(), and its expiry is counted from PublishAt rather than
+// from now. PublishAt is converted to UTC before it is stored, so that it can
+// be compared directly with UTC_TIMESTAMP().
+//
+// If quota isn't nil, the owner's usage is checked against it in the same
+// transaction as the insert, and ErrOverQuota is returned if the snippet
+// doesn't fit.
+func (m *SnippetModel) Insert(snippet *Snippet, expires int, quota *Quota) (int, error) {
+	query := `INSERT INTO snippets (user_id, kind, title, content, key_id, content_hash, language, language_confidence,
+	published, publish_at, forked_from, license, source_url, attribution, delete_token_hash, size, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(COALESCE(?, UTC_TIMESTAMP()), INTERVAL ? DAY))`
+
+	if snippet.Kind == "" {
+		snippet.Kind = KindPlain
+	}
+
+	snippet.ContentHash = ContentHash(snippet.Content)
+
+	keyID, content, err := sealContent(m.Keys, snippet.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	var publishAt sql.NullTime
+	if !snippet.PublishAt.IsZero() {
+		publishAt = sql.NullTime{Time: snippet.PublishAt.UTC(), Valid: true}
+	}
+	snippet.Published = !publishAt.Valid
+
+	var forkedFrom sql.NullInt64
+	if snippet.ForkedFrom != 0 {
+		forkedFrom = sql.NullInt64{Int64: int64(snippet.ForkedFrom), Valid: true}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if quota != nil {
+		u, err := lockUsage(tx, snippet.UserID)
+		if err != nil {
+			return 0, err
+		}
+		if quota.SnippetsFull(u) || quota.HourFull(u) || quota.TooLarge(u, len(snippet.Content)) {
+			return 0, ErrOverQuota
+		}
+	}
 
-	result, err := m.DB.Exec(query, title, content, expires)
+	result, err := tx.Exec(query, snippet.UserID, snippet.Kind, snippet.Title, content, keyID, snippet.ContentHash,
+		snippet.Language, snippet.LanguageConfidence, snippet.Published, publishAt, forkedFrom,
+		snippet.License, snippet.SourceURL, snippet.Attribution, snippet.DeleteTokenHash, len(snippet.Content), publishAt, expires)
 	if err != nil {
 		return 0, err
 	}
@@ -37,19 +257,22 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	if err != nil {
 		return 0, err
 	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
 	return int(id), nil
 }
 
+// Get returns a live snippet, whether or not it has been published yet. It
+// is up to the caller to hide unpublished snippets from everyone but their
+// owner.
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
+	query := `SELECT ` + snippetColumns + ` FROM snippets
 	WHERE expires > UTC_TIMESTAMP() AND id = ?`
 
-	row := m.DB.QueryRow(query, id)
-
-	s := &Snippet{}
-
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
-
+	s, err := scanSnippet(m.DB.QueryRow(query, id), m.Keys)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -62,8 +285,9 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 }
 
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	query := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	query := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND published = TRUE AND kind = 'plain'
+	ORDER BY created DESC LIMIT 10`
 
 	rows, err := m.DB.Query(query)
 
@@ -76,8 +300,36 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	snippets := []*Snippet{}
 
 	for rows.Next() {
-		s := &Snippet{}
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// AllPublished returns every live, published snippet, leaving out secret
+// snippets. It is used to build in-memory indexes at startup.
+func (m *SnippetModel) AllPublished() ([]*Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND published = TRUE AND kind = 'plain'`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows, m.Keys)
 		if err != nil {
 			return nil, err
 		}
@@ -90,3 +342,287 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	return snippets, nil
 }
+
+// FindDuplicate returns the most recent live snippet owned by userID whose
+// normalised content hash equals contentHash. Secret snippets and scheduled
+// ones which haven't been published yet can't stand in for a new snippet, so
+// they are never returned. If there is no such snippet it returns ErrNoRecord.
+func (m *SnippetModel) FindDuplicate(userID int, contentHash string) (*Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE user_id = ? AND content_hash = ? AND expires > UTC_TIMESTAMP() AND published = TRUE AND kind = 'plain'
+	ORDER BY created DESC LIMIT 1`
+
+	s, err := scanSnippet(m.DB.QueryRow(query, userID, contentHash), m.Keys)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// SetLanguage records a language chosen by the user for a live snippet,
+// replacing any detected language and clearing its confidence score.
+func (m *SnippetModel) SetLanguage(id int, language string) error {
+	query := `UPDATE snippets SET language = ?, language_confidence = 0
+	WHERE id = ? AND expires > UTC_TIMESTAMP()`
+
+	_, err := m.DB.Exec(query, language, id)
+	return err
+}
+
+// Revise replaces the content of a live snippet, first saving the current
+// content to the snippet_revisions table so that earlier versions are kept.
+// Both statements run in a single transaction.
+//
+// If quota isn't nil, ErrOverQuota is returned when the new content would
+// take the owner over their storage limit.
+func (m *SnippetModel) Revise(id int, content string, quota *Quota) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if quota != nil {
+		// Lock the snippet before reading the usage, so that its size
+		// can't change in the meantime.
+		var userID, size int
+		query := `SELECT user_id, size FROM snippets WHERE id = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+		err = tx.QueryRow(query, id).Scan(&userID, &size)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+
+		u, err := lockUsage(tx, userID)
+		if err != nil {
+			return err
+		}
+		if quota.TooLarge(u, len(content)-size) {
+			return ErrOverQuota
+		}
+	}
+
+	// The current content is copied as it is stored, so an encrypted
+	// revision keeps the key it was encrypted with.
+	query := `INSERT INTO snippet_revisions (snippet_id, content, key_id, created)
+	SELECT id, content, key_id, UTC_TIMESTAMP() FROM snippets
+	WHERE id = ? AND expires > UTC_TIMESTAMP()`
+
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	keyID, stored, err := sealContent(m.Keys, content)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE snippets SET content = ?, key_id = ?, content_hash = ?, size = ? WHERE id = ?`
+
+	_, err = tx.Exec(query, stored, keyID, ContentHash(content), len(content), id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PublishDue publishes every scheduled snippet whose publish time has passed,
+// and returns the snippets which were published.
+func (m *SnippetModel) PublishDue() ([]*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE published = FALSE AND publish_at <= UTC_TIMESTAMP() FOR UPDATE`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range snippets {
+		_, err = tx.Exec(`UPDATE snippets SET published = TRUE WHERE id = ?`, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		s.Published = true
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// DeleteWithToken deletes a snippet, along with its revisions, if tokenHash
+// matches the hash of its delete token. It returns ErrNoRecord if there is no
+// such snippet or the hash doesn't match. Snippets created without a delete
+// token can't be deleted this way.
+func (m *SnippetModel) DeleteWithToken(id int, tokenHash string) error {
+	if tokenHash == "" {
+		return ErrNoRecord
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM snippets WHERE id = ? AND delete_token_hash = ?`, id, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_revisions WHERE snippet_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Counts counts the snippets owned by a user.
+func (m *SnippetModel) Counts(userID int) (*SnippetCounts, error) {
+	c := &SnippetCounts{}
+
+	query := `SELECT
+		COUNT(CASE WHEN expires > UTC_TIMESTAMP() AND published = TRUE THEN 1 END),
+		COUNT(CASE WHEN expires > UTC_TIMESTAMP() AND published = FALSE THEN 1 END),
+		COUNT(CASE WHEN expires <= UTC_TIMESTAMP() THEN 1 END)
+	FROM snippets WHERE user_id = ?`
+
+	err := m.DB.QueryRow(query, userID).Scan(&c.Live, &c.Scheduled, &c.Expired)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Rekey re-encrypts up to batchSize rows of snippet or revision content which
+// isn't already encrypted with the primary key, including rows stored
+// unencrypted. It returns the number of rows updated, so callers should keep
+// calling it until it returns 0. Each batch runs in its own transaction.
+func (m *SnippetModel) Rekey(batchSize int) (int, error) {
+	if m.Keys == nil {
+		return 0, errors.New("models: rekey needs a keyring")
+	}
+
+	n, err := rekeyTable(m.DB, m.Keys, "snippets", "id", "content", batchSize)
+	if err != nil || n > 0 {
+		return n, err
+	}
+	return rekeyTable(m.DB, m.Keys, "snippet_revisions", "id", "content", batchSize)
+}
+
+// rekeyTable re-encrypts up to batchSize values of column in table which
+// aren't encrypted with the primary key, in one transaction. The table's key
+// ID is stored in its key_id column, and idColumn identifies its rows.
+func rekeyTable(db *sql.DB, keys *keyring.Keyring, table, idColumn, column string, batchSize int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + idColumn + `, ` + column + `, key_id FROM ` + table + `
+	WHERE key_id <> ? ORDER BY ` + idColumn + ` LIMIT ? FOR UPDATE`
+
+	rows, err := tx.Query(query, keys.Primary(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	type row struct {
+		id      int
+		content string
+		keyID   string
+	}
+	var batch []row
+
+	for rows.Next() {
+		var r row
+		err := rows.Scan(&r.id, &r.content, &r.keyID)
+		if err != nil {
+			return 0, err
+		}
+		batch = append(batch, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	stmt := `UPDATE ` + table + ` SET ` + column + ` = ?, key_id = ? WHERE ` + idColumn + ` = ?`
+
+	for _, r := range batch {
+		plaintext, err := openContent(keys, r.keyID, r.content)
+		if err != nil {
+			return 0, fmt.Errorf("%s %d: %w", table, r.id, err)
+		}
+
+		keyID, stored, err := sealContent(keys, plaintext)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec(stmt, stored, keyID, r.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(batch), nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+)
+
+func TestSealContent(t *testing.T) {
+	keys, err := keyring.Parse("k1:Z1sfQNN3CHC0cEeF4y4R8g==")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name      string
+		keys      *keyring.Keyring
+		wantKeyID string
+	}{
+		{name: "Plain text", keys: nil, wantKeyID: ""},
+		{name: "Encrypted", keys: keys, wantKeyID: "k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, stored, err := sealContent(tt.keys, "An old silent pond...")
+			assert.NilError(t, err)
+			assert.Equal(t, keyID, tt.wantKeyID)
+			if tt.keys != nil && stored == "An old silent pond..." {
+				t.Error("content was stored unencrypted")
+			}
+
+			content, err := openContent(tt.keys, keyID, stored)
+			assert.NilError(t, err)
+			assert.Equal(t, content, "An old silent pond...")
+		})
+	}
+
+	// Encrypted content can't be read back without the keyring.
+	keyID, stored, err := sealContent(keys, "An old silent pond...")
+	assert.NilError(t, err)
+	_, err = openContent(nil, keyID, stored)
+	assert.Equal(t, err, keyring.ErrUnknownKey)
+}
+
+func TestSnippetModelFindDuplicate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name    string
+		snippet *Snippet
+		wantErr error
+	}{
+		{
+			name:    "Live",
+			snippet: &Snippet{UserID: 1, Title: "Haiku", Content: "A frog jumps into the pond"},
+		},
+		{
+			name:    "Secret",
+			snippet: &Snippet{UserID: 1, Kind: KindSecret, Title: "Haiku", Content: "A frog jumps into the pond"},
+			wantErr: ErrNoRecord,
+		},
+		{
+			name:    "Scheduled",
+			snippet: &Snippet{UserID: 1, Title: "Haiku", Content: "A frog jumps into the pond", PublishAt: time.Now().Add(time.Hour)},
+			wantErr: ErrNoRecord,
+		},
+		{
+			name:    "Other user",
+			snippet: &Snippet{UserID: 2, Title: "Haiku", Content: "A frog jumps into the pond"},
+			wantErr: ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := SnippetModel{DB: newTestDB(t)}
+
+			id, err := m.Insert(tt.snippet, 7, nil)
+			assert.NilError(t, err)
+
+			s, err := m.FindDuplicate(1, ContentHash("A frog jumps into the pond"))
+			assert.Equal(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.Equal(t, s.ID, id)
+			}
+		})
+	}
+}
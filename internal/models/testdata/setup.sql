@@ -1,23 +1,178 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    kind VARCHAR(16) NOT NULL DEFAULT 'plain',
     title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    key_id VARCHAR(32) NOT NULL DEFAULT '',
+    content_hash CHAR(64) NOT NULL DEFAULT '',
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    language_confidence FLOAT NOT NULL DEFAULT 0,
+    published BOOLEAN NOT NULL DEFAULT TRUE,
+    publish_at DATETIME NULL,
+    forked_from INTEGER NULL,
+    license VARCHAR(64) NOT NULL DEFAULT '',
+    source_url VARCHAR(2048) NOT NULL DEFAULT '',
+    attribution VARCHAR(255) NOT NULL DEFAULT '',
+    delete_token_hash CHAR(64) NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
     created DATETIME NOT NULL,
     expires DATETIME NOT NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_content_hash ON snippets(user_id, content_hash);
+CREATE INDEX idx_snippets_published_publish_at ON snippets(published, publish_at);
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
+CREATE INDEX idx_snippets_key_id ON snippets(key_id);
+CREATE INDEX idx_snippets_user_id_expires ON snippets(user_id, expires);
+
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    key_id VARCHAR(32) NOT NULL DEFAULT '',
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions(snippet_id);
+CREATE INDEX idx_snippet_revisions_key_id ON snippet_revisions(key_id);
+
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+ALTER TABLE collections ADD CONSTRAINT collections_uc_slug UNIQUE (slug);
+CREATE INDEX idx_collections_user_id ON collections(user_id);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE TABLE featured_snippets (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    position INTEGER NOT NULL,
+    pinned DATETIME NOT NULL
+);
+
+CREATE TABLE snippet_views (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    viewer CHAR(64) NOT NULL,
+    bucket INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    UNIQUE KEY (snippet_id, viewer, bucket)
+);
+
+CREATE INDEX idx_snippet_views_created ON snippet_views(created);
+
+CREATE TABLE snippet_stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_snippet_stars_created ON snippet_stars(created);
+
+CREATE TABLE trending_snippets (
+    snippet_id INTEGER NOT NULL PRIMARY KEY,
+    score DOUBLE NOT NULL,
+    computed DATETIME NOT NULL
+);
+
+CREATE INDEX idx_trending_snippets_score ON trending_snippets(score);
 
 CREATE TABLE users (
 id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
     name VARCHAR(255) NOT NULL,
     email VARCHAR(255) NOT NULL,
     hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
+    created DATETIME NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    activated BOOLEAN NOT NULL DEFAULT TRUE
 );
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
+CREATE TABLE tokens (
+    hash CHAR(64) NOT NULL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_tokens_user_id ON tokens(user_id);
+
+CREATE TABLE sessions (
+    token CHAR(43) PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+CREATE TABLE user_sessions (
+    token CHAR(43) NOT NULL PRIMARY KEY,
+    id INTEGER NOT NULL AUTO_INCREMENT UNIQUE,
+    user_id INTEGER NOT NULL,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    remember_selector CHAR(16),
+    created DATETIME NOT NULL,
+    last_seen DATETIME NOT NULL
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions(user_id);
+
+CREATE TABLE user_totp (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    key_id VARCHAR(32) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created DATETIME NOT NULL
+);
+
+CREATE TABLE recovery_codes (
+    user_id INTEGER NOT NULL,
+    hash CHAR(64) NOT NULL,
+    PRIMARY KEY (user_id, hash)
+);
+
+CREATE TABLE throttle_failures (
+    throttle_key VARCHAR(320) NOT NULL PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure DATETIME(6) NOT NULL
+);
+
+CREATE INDEX idx_throttle_failures_last_failure ON throttle_failures(last_failure);
+
+CREATE TABLE remember_tokens (
+    selector CHAR(16) NOT NULL PRIMARY KEY,
+    validator_hash CHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    expiry DATETIME NOT NULL
+);
+
+CREATE INDEX idx_remember_tokens_user_id ON remember_tokens(user_id);
+
+CREATE TABLE user_quotas (
+    user_id INTEGER NOT NULL PRIMARY KEY,
+    max_snippets INTEGER NOT NULL,
+    max_bytes INTEGER NOT NULL,
+    max_per_hour INTEGER NOT NULL
+);
+
 INSERT INTO users (name, email, hashed_password, created) VALUES (
     'Alice Jones',
     'alice@example.com',
@@ -1,3 +1,33 @@
+DROP TABLE tokens;
+
+DROP TABLE user_sessions;
+
+DROP TABLE sessions;
+
+DROP TABLE remember_tokens;
+
+DROP TABLE throttle_failures;
+
+DROP TABLE recovery_codes;
+
+DROP TABLE user_totp;
+
+DROP TABLE user_quotas;
+
 DROP TABLE users;
 
+DROP TABLE trending_snippets;
+
+DROP TABLE snippet_stars;
+
+DROP TABLE snippet_views;
+
+DROP TABLE featured_snippets;
+
+DROP TABLE collection_snippets;
+
+DROP TABLE collections;
+
+DROP TABLE snippet_revisions;
+
 DROP TABLE snippets;
\ No newline at end of file
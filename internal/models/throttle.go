@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/throttle"
+)
+
+// ThrottleModel is a throttle.Store which keeps failure counts in MySQL, so
+// that they are shared by every instance of the application and survive
+// restarts.
+type ThrottleModel struct {
+	DB *sql.DB
+}
+
+func (m *ThrottleModel) Get(key string) (throttle.Record, error) {
+	var rec throttle.Record
+
+	stmt := `SELECT failures, last_failure FROM throttle_failures WHERE throttle_key = ?`
+
+	err := m.DB.QueryRow(stmt, key).Scan(&rec.Failures, &rec.Last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return throttle.Record{}, err
+	}
+
+	return rec, nil
+}
+
+// Fail adds a failure to the record for key. The failure count is reset in
+// the same statement if the last failure is older than window, before
+// last_failure itself is updated.
+func (m *ThrottleModel) Fail(key string, now time.Time, window time.Duration) (throttle.Record, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return throttle.Record{}, err
+	}
+	defer tx.Rollback()
+
+	now = now.UTC()
+
+	stmt := `INSERT INTO throttle_failures (throttle_key, failures, last_failure) VALUES (?, 1, ?)
+	ON DUPLICATE KEY UPDATE failures = IF(last_failure < ?, 1, failures + 1), last_failure = VALUES(last_failure)`
+
+	_, err = tx.Exec(stmt, key, now, now.Add(-window))
+	if err != nil {
+		return throttle.Record{}, err
+	}
+
+	// Expired records are cleared out as new failures come in.
+	_, err = tx.Exec(`DELETE FROM throttle_failures WHERE last_failure < ?`, now.Add(-window))
+	if err != nil {
+		return throttle.Record{}, err
+	}
+
+	var rec throttle.Record
+
+	stmt = `SELECT failures, last_failure FROM throttle_failures WHERE throttle_key = ?`
+
+	err = tx.QueryRow(stmt, key).Scan(&rec.Failures, &rec.Last)
+	if err != nil {
+		return throttle.Record{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return throttle.Record{}, err
+	}
+
+	return rec, nil
+}
+
+// Reserve adds a failure to the record for key unless wait says it must
+// wait. The row for key is made first if there isn't one, with no failures,
+// so that it can be locked while it's checked and updated.
+func (m *ThrottleModel) Reserve(key string, now time.Time, window time.Duration, wait func(throttle.Record) time.Duration) (throttle.Record, time.Duration, error) {
+	// The time is stored to the microsecond, so it is truncated here to
+	// match what Release compares it with.
+	now = now.UTC().Truncate(time.Microsecond)
+
+	stmt := `INSERT INTO throttle_failures (throttle_key, failures, last_failure) VALUES (?, 0, ?)
+	ON DUPLICATE KEY UPDATE throttle_key = throttle_key`
+
+	_, err := m.DB.Exec(stmt, key, now)
+	if err != nil {
+		return throttle.Record{}, 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return throttle.Record{}, 0, err
+	}
+	defer tx.Rollback()
+
+	var rec throttle.Record
+
+	stmt = `SELECT failures, last_failure FROM throttle_failures WHERE throttle_key = ? FOR UPDATE`
+
+	err = tx.QueryRow(stmt, key).Scan(&rec.Failures, &rec.Last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return throttle.Record{}, 0, err
+	}
+
+	prior := rec.Last
+	if now.Sub(rec.Last) > window {
+		rec = throttle.Record{}
+	}
+	if d := wait(rec); d > 0 {
+		return rec, d, nil
+	}
+
+	stmt = `INSERT INTO throttle_failures (throttle_key, failures, last_failure) VALUES (?, 1, ?)
+	ON DUPLICATE KEY UPDATE failures = IF(last_failure < ?, 1, failures + 1), last_failure = VALUES(last_failure)`
+
+	_, err = tx.Exec(stmt, key, now, now.Add(-window))
+	if err != nil {
+		return throttle.Record{}, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return throttle.Record{}, 0, err
+	}
+
+	return throttle.Record{Failures: rec.Failures + 1, Last: now, Prior: prior}, 0, nil
+}
+
+// Release takes back a reserved failure. The time of the last failure is
+// only put back if it is still the reserved one; a row whose count drops to
+// zero keeps its time, since it no longer matters.
+func (m *ThrottleModel) Release(key string, rec throttle.Record) error {
+	prior := rec.Prior
+	if prior.IsZero() {
+		prior = rec.Last
+	}
+
+	stmt := `UPDATE throttle_failures SET failures = failures - 1,
+		last_failure = IF(last_failure = ?, ?, last_failure)
+	WHERE throttle_key = ? AND failures > 0`
+
+	_, err := m.DB.Exec(stmt, rec.Last.UTC(), prior.UTC(), key)
+	return err
+}
+
+func (m *ThrottleModel) Reset(key string) error {
+	_, err := m.DB.Exec(`DELETE FROM throttle_failures WHERE throttle_key = ?`, key)
+	return err
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/throttle"
+)
+
+func TestThrottleModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := ThrottleModel{newTestDB(t)}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	rec, err := m.Get("account:alice@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 0)
+
+	for i := 1; i <= 3; i++ {
+		rec, err = m.Fail("account:alice@example.com", now, time.Hour)
+		assert.NilError(t, err)
+		assert.Equal(t, rec.Failures, i)
+	}
+
+	rec, err = m.Get("account:alice@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 3)
+	assert.Equal(t, rec.Last.Equal(now), true)
+
+	// A failure after the window starts the count again.
+	rec, err = m.Fail("account:alice@example.com", now.Add(2*time.Hour), time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 1)
+
+	err = m.Reset("account:alice@example.com")
+	assert.NilError(t, err)
+
+	rec, err = m.Get("account:alice@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 0)
+}
+
+func TestThrottleModelReserve(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	m := ThrottleModel{newTestDB(t)}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	// Attempts are allowed while there are fewer than two failures.
+	wait := func(rec throttle.Record) time.Duration {
+		if rec.Failures >= 2 {
+			return time.Minute
+		}
+		return 0
+	}
+
+	rec, d, err := m.Reserve("account:alice@example.com", now, time.Hour, wait)
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 1)
+	assert.Equal(t, d, time.Duration(0))
+
+	// A reserved attempt which is given back doesn't move the time of the
+	// last failure.
+	reserved, d, err := m.Reserve("account:alice@example.com", now.Add(time.Second), time.Hour, wait)
+	assert.NilError(t, err)
+	assert.Equal(t, reserved.Failures, 2)
+	assert.Equal(t, d, time.Duration(0))
+
+	rec, d, err = m.Reserve("account:alice@example.com", now.Add(time.Second), time.Hour, wait)
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 2)
+	assert.Equal(t, d, time.Minute)
+
+	err = m.Release("account:alice@example.com", reserved)
+	assert.NilError(t, err)
+
+	rec, err = m.Get("account:alice@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 1)
+	assert.Equal(t, rec.Last.Equal(now), true)
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// The scopes of tokens. A token can only be used for the purpose it was
+// issued for.
+const (
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
+)
+
+type TokenModelInterface interface {
+	Insert(tokenHash string, userID int, scope string, ttl time.Duration) error
+	Consume(tokenHash, scope string) (int, error)
+	DeleteAllForUser(scope string, userID int) error
+}
+
+// TokenModel stores single-use tokens which are sent to users by email. Only
+// the SHA-256 hash of each token is stored, so that the tokens can't be used
+// by someone who can read the database.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert stores the hash of a token for the user, which expires after ttl.
+func (m *TokenModel) Insert(tokenHash string, userID int, scope string, ttl time.Duration) error {
+	stmt := `INSERT INTO tokens (hash, user_id, scope, expiry)
+	VALUES (?, ?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err := m.DB.Exec(stmt, tokenHash, userID, scope, int(ttl.Seconds()))
+	return err
+}
+
+// Consume uses up a token, returning the ID of the user it was issued to. It
+// returns ErrNoRecord if there is no unexpired token with the given hash and
+// scope. A token can only be consumed once.
+func (m *TokenModel) Consume(tokenHash, scope string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM tokens
+	WHERE hash = ? AND scope = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, tokenHash, scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM tokens WHERE hash = ?`, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// DeleteAllForUser deletes the user's tokens of the given scope, along with
+// any expired tokens of theirs.
+func (m *TokenModel) DeleteAllForUser(scope string, userID int) error {
+	stmt := `DELETE FROM tokens WHERE user_id = ? AND (scope = ? OR expiry <= UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, scope)
+	return err
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+)
+
+// The weights given to each kind of event when scoring trending snippets.
+// A star or a fork says much more about a snippet than a single view.
+const (
+	viewWeight = 1
+	starWeight = 5
+	forkWeight = 10
+)
+
+type TrendingModelInterface interface {
+	RecordView(snippetID int, viewer string) error
+	Star(userID, snippetID int) error
+	Unstar(userID, snippetID int) error
+	Starred(userID, snippetID int) (bool, error)
+	Recompute(halfLife, window time.Duration) error
+	Top(n int) ([]*Snippet, error)
+}
+
+// TrendingModel records the views and stars of snippets, and ranks them into
+// the trending_snippets table. Forks are recorded by the forked_from column
+// of the snippets table.
+type TrendingModel struct {
+	DB *sql.DB
+	// Keys decrypts the content of snippets, as in SnippetModel.
+	Keys *keyring.Keyring
+}
+
+// RecordView records a view of a snippet by viewer, which is any string
+// identifying who is looking, such as a hash of their IP address. Each viewer
+// is only counted once per snippet per hour, so that reloading the page over
+// and over doesn't push a snippet up the trending list.
+func (m *TrendingModel) RecordView(snippetID int, viewer string) error {
+	stmt := `INSERT IGNORE INTO snippet_views (snippet_id, viewer, bucket, created)
+	VALUES(?, ?, FLOOR(UNIX_TIMESTAMP() / 3600), UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, snippetID, viewer)
+	return err
+}
+
+// Star records that a user has starred a snippet. Starring a snippet twice
+// does nothing.
+func (m *TrendingModel) Star(userID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO snippet_stars (user_id, snippet_id, created)
+	VALUES(?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+func (m *TrendingModel) Unstar(userID, snippetID int) error {
+	stmt := `DELETE FROM snippet_stars WHERE user_id = ? AND snippet_id = ?`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+func (m *TrendingModel) Starred(userID, snippetID int) (bool, error) {
+	var starred bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM snippet_stars WHERE user_id = ? AND snippet_id = ?)`
+
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// Recompute replaces the contents of the trending_snippets table with fresh
+// scores. Each view, star and fork within the window adds its weight to the
+// snippet's score, halved for every halfLife that has passed since it
+// happened. Views older than the window no longer count towards any score,
+// so they are deleted at the same time.
+func (m *TrendingModel) Recompute(halfLife, window time.Duration) error {
+	cutoff := time.Now().UTC().Add(-window)
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM trending_snippets`)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO trending_snippets (snippet_id, score, computed)
+	SELECT events.snippet_id,
+		SUM(events.weight * POW(0.5, TIMESTAMPDIFF(SECOND, events.created, UTC_TIMESTAMP()) / ?)),
+		UTC_TIMESTAMP()
+	FROM (
+		SELECT snippet_id, ? AS weight, created FROM snippet_views WHERE created > ?
+		UNION ALL
+		SELECT snippet_id, ?, created FROM snippet_stars WHERE created > ?
+		UNION ALL
+		SELECT forked_from, ?, created FROM snippets WHERE forked_from IS NOT NULL AND created > ?
+	) AS events
+	INNER JOIN snippets ON snippets.id = events.snippet_id
+	WHERE snippets.published = TRUE AND snippets.kind = 'plain' AND snippets.expires > UTC_TIMESTAMP()
+	GROUP BY events.snippet_id`
+
+	_, err = tx.Exec(stmt, halfLife.Seconds(),
+		viewWeight, cutoff, starWeight, cutoff, forkWeight, cutoff)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_views WHERE created <= ?`, cutoff)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Top returns the n highest scoring snippets as of the last call to
+// Recompute, leaving out any which have expired since.
+func (m *TrendingModel) Top(n int) ([]*Snippet, error) {
+	query := `SELECT ` + snippetColumns + ` FROM trending_snippets
+	INNER JOIN snippets ON snippets.id = trending_snippets.snippet_id
+	WHERE snippets.expires > UTC_TIMESTAMP()
+	ORDER BY trending_snippets.score DESC LIMIT ?`
+
+	rows, err := m.DB.Query(query, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestTrendingModelRecordView(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := TrendingModel{DB: db}
+
+	// Reloading the page doesn't count as another view, but another viewer
+	// does.
+	for _, viewer := range []string{"alice", "alice", "alice", "bob"} {
+		err := m.RecordView(1, viewer)
+		assert.NilError(t, err)
+	}
+
+	var views int
+	err := db.QueryRow(`SELECT COUNT(*) FROM snippet_views WHERE snippet_id = 1`).Scan(&views)
+	assert.NilError(t, err)
+	assert.Equal(t, views, 2)
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/totp"
+)
+
+type TwoFactorModelInterface interface {
+	Enabled(userID int) (bool, error)
+	Secret(userID int) ([]byte, error)
+	SetSecret(userID int, secret []byte) error
+	Enable(userID int, step int64, recoveryCodeHashes []string) error
+	Disable(userID int) error
+	Validate(userID int, code string, t time.Time) error
+	UseRecoveryCode(userID int, codeHash string) error
+	RecoveryCodesLeft(userID int) (int, error)
+}
+
+// TwoFactorModel stores users' TOTP secrets, encrypted with Keys, and the
+// SHA-256 hashes of their one-time recovery codes. A secret is pending from
+// when the user starts enrolling until they prove their authenticator app
+// has it by entering a code, at which point two-factor authentication is
+// enabled.
+type TwoFactorModel struct {
+	DB   *sql.DB
+	Keys *keyring.Keyring
+}
+
+// Enabled reports whether the user has two-factor authentication turned on.
+func (m *TwoFactorModel) Enabled(userID int) (bool, error) {
+	var enabled bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM user_totp WHERE user_id = ? AND enabled = TRUE)`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// Secret returns the user's pending secret, or ErrNoRecord if they don't
+// have one. The secret of an enabled user is never returned, so it can't be
+// shown again.
+func (m *TwoFactorModel) Secret(userID int) ([]byte, error) {
+	var keyID, stored string
+
+	stmt := `SELECT key_id, secret FROM user_totp WHERE user_id = ? AND enabled = FALSE`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&keyID, &stored)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	secret, err := openContent(m.Keys, keyID, stored)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(secret), nil
+}
+
+// SetSecret stores a pending secret for the user, replacing any earlier
+// pending one. It does nothing if the user already has two-factor
+// authentication enabled.
+func (m *TwoFactorModel) SetSecret(userID int, secret []byte) error {
+	if m.Keys == nil {
+		return ErrNoKeys
+	}
+
+	keyID, sealed, err := sealContent(m.Keys, string(secret))
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO user_totp (user_id, key_id, secret, created)
+	VALUES (?, ?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE key_id = IF(enabled, key_id, VALUES(key_id)),
+		secret = IF(enabled, secret, VALUES(secret)), created = IF(enabled, created, VALUES(created))`
+
+	_, err = m.DB.Exec(stmt, userID, keyID, sealed)
+	return err
+}
+
+// Enable turns on two-factor authentication with the user's pending secret,
+// once they have entered a code from step, and replaces their recovery codes.
+// It returns ErrNoRecord if there is no pending secret.
+func (m *TwoFactorModel) Enable(userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE user_totp SET enabled = TRUE, last_step = ? WHERE user_id = ? AND enabled = FALSE`
+
+	result, err := tx.Exec(stmt, step, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.Exec(`INSERT INTO recovery_codes (user_id, hash) VALUES (?, ?)`, userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Disable turns off two-factor authentication, deleting the user's secret
+// and recovery codes.
+func (m *TwoFactorModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Validate checks a code from the user's authenticator app at time t. It
+// returns ErrInvalidCredentials if the code is wrong, or if it is from a step
+// no later than the last code accepted, so that each code works only once.
+func (m *TwoFactorModel) Validate(userID int, code string, t time.Time) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keyID, stored string
+	var lastStep int64
+
+	stmt := `SELECT key_id, secret, last_step FROM user_totp
+	WHERE user_id = ? AND enabled = TRUE FOR UPDATE`
+
+	err = tx.QueryRow(stmt, userID).Scan(&keyID, &stored, &lastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	secret, err := openContent(m.Keys, keyID, stored)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate([]byte(secret), code, t, 1)
+	if !ok || step <= lastStep {
+		return ErrInvalidCredentials
+	}
+
+	_, err = tx.Exec(`UPDATE user_totp SET last_step = ? WHERE user_id = ?`, step, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode uses up one of the user's recovery codes. It returns
+// ErrInvalidCredentials if they have no code with the given hash.
+func (m *TwoFactorModel) UseRecoveryCode(userID int, codeHash string) error {
+	result, err := m.DB.Exec(`DELETE FROM recovery_codes WHERE user_id = ? AND hash = ?`, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+// RecoveryCodesLeft returns the number of the user's recovery codes which
+// haven't been used.
+func (m *TwoFactorModel) RecoveryCodesLeft(userID int) (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?`, userID).Scan(&n)
+	return n, err
+}
+
+// Rekey re-encrypts up to batchSize secrets which aren't already encrypted
+// with the primary key. It returns the number of secrets updated, so callers
+// should keep calling it until it returns 0.
+func (m *TwoFactorModel) Rekey(batchSize int) (int, error) {
+	if m.Keys == nil {
+		return 0, ErrNoKeys
+	}
+
+	return rekeyTable(m.DB, m.Keys, "user_totp", "user_id", "secret", batchSize)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/keyring"
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/totp"
+)
+
+func TestTwoFactorModelRekey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	oldKeys, err := keyring.Parse("k1:Z1sfQNN3CHC0cEeF4y4R8g==")
+	assert.NilError(t, err)
+	bothKeys, err := keyring.Parse("k2:N+AcxfulEdTl/ZzyR1bZuQ==,k1:Z1sfQNN3CHC0cEeF4y4R8g==")
+	assert.NilError(t, err)
+	newKeys, err := keyring.Parse("k2:N+AcxfulEdTl/ZzyR1bZuQ==")
+	assert.NilError(t, err)
+
+	db := newTestDB(t)
+
+	secret, err := totp.NewSecret()
+	assert.NilError(t, err)
+
+	m := TwoFactorModel{DB: db, Keys: oldKeys}
+	err = m.SetSecret(1, secret)
+	assert.NilError(t, err)
+	err = m.Enable(1, 0, nil)
+	assert.NilError(t, err)
+
+	m = TwoFactorModel{DB: db, Keys: bothKeys}
+	n, err := m.Rekey(100)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.Rekey(100)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	// Once rekeyed, the old key can be removed.
+	m = TwoFactorModel{DB: db, Keys: newKeys}
+	now := time.Now()
+	err = m.Validate(1, totp.Code(secret, now), now)
+	assert.NilError(t, err)
+}
@@ -14,6 +14,12 @@ type UserModelInterface interface {
 	Insert(name, email, password string) error
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+	PasswordUpdate(id int, currentPassword, newPassword string) error
+	PasswordReset(tokenHash, newPassword string) (int, error)
+	GetByEmail(email string) (*User, error)
+	Activate(id int) error
+	IsAdmin(id int) (bool, error)
 }
 
 type User struct {
@@ -22,15 +28,24 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	// Activated is false until the user has verified their email address.
+	Activated bool
 }
 
 type UserModel struct {
 	DB *sql.DB
 }
 
+// dummyHash is a bcrypt hash, at the same cost as users' password hashes, of
+// a random password which has been thrown away. Authenticate compares
+// against it when there is no user with the email address, so that it takes
+// as long as when there is.
+var dummyHash = []byte("$2a$12$s5qx.EFayOTqQl7BUB1Txud.urgRZDYebsRzkjv1.CyLcRYGpUPOm")
+
 // Insert inserts a new user into the database with the provided name, email, and password.
 // It creates a bcrypt hash of the plain-text password and stores it in the "hashed_password" column.
-// The "created" column is set to the current UTC timestamp.
+// The "created" column is set to the current UTC timestamp. New users aren't
+// activated until they verify their email address.
 //
 // Parameters:
 // - name: the name of the user.
@@ -46,8 +61,8 @@ func (m *UserModel) Insert(name, email, password string) error {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, hashed_password, created, activated)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), FALSE)`
 
 	// Use the Exec() method to insert the user details and hashed password
 	// into the users table.
@@ -71,7 +86,9 @@ func (m *UserModel) Insert(name, email, password string) error {
 	return nil
 }
 
-// Authenticate authenticates a user based on their email and password.
+// Authenticate authenticates a user based on their email and password. It
+// does the same work whether or not there is a user with the email address,
+// so the time it takes doesn't reveal which addresses are registered.
 //
 // Parameters:
 // - email: the email of the user
@@ -89,6 +106,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
@@ -116,3 +134,137 @@ func (m *UserModel) Exists(id int) (bool, error) {
 	err := m.DB.QueryRow(stmt, id).Scan(&exists)
 	return exists, err
 }
+
+// Get returns the user with the given ID, or ErrNoRecord if there isn't one.
+// The password hash is not read back.
+func (m *UserModel) Get(id int) (*User, error) {
+	u := &User{}
+
+	stmt := "SELECT id, name, email, created, activated FROM users WHERE id = ?"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// PasswordUpdate replaces the user's password with a bcrypt hash of
+// newPassword, as long as currentPassword matches their existing password.
+// If it doesn't, it returns ErrInvalidCredentials.
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+
+	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&currentHashedPassword)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt = "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+	_, err = m.DB.Exec(stmt, newHashedPassword, id)
+	return err
+}
+
+// GetByEmail returns the user with the given email address, or ErrNoRecord
+// if there isn't one.
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	u := &User{}
+
+	stmt := "SELECT id, name, email, created, activated FROM users WHERE email = ?"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Activated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// PasswordReset sets a new password for the user who was issued the password
+// reset token with the given hash, without checking their current one, and
+// returns their ID. The token, and any other reset tokens of theirs, are used
+// up in the same transaction, so a failed update leaves the link working. It
+// returns ErrNoRecord if there is no unexpired token with the given hash.
+func (m *UserModel) PasswordReset(tokenHash, newPassword string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+
+	stmt := `SELECT user_id FROM tokens
+	WHERE hash = ? AND scope = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, tokenHash, ScopePasswordReset).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", hashedPassword, id)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("DELETE FROM tokens WHERE user_id = ? AND scope = ?", id, ScopePasswordReset)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Activate marks the user's email address as verified.
+func (m *UserModel) Activate(id int) error {
+	_, err := m.DB.Exec("UPDATE users SET activated = TRUE WHERE id = ?", id)
+	return err
+}
+
+// IsAdmin reports whether the user with the given ID is an administrator.
+// Administrators are appointed by setting users.is_admin directly in the
+// database.
+func (m *UserModel) IsAdmin(id int) (bool, error) {
+	var isAdmin bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND is_admin = TRUE)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&isAdmin)
+	return isAdmin, err
+}
@@ -2,8 +2,10 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUserModelExists(t *testing.T) {
@@ -51,3 +53,90 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{db}
+
+	user, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Name, "Alice Jones")
+	assert.Equal(t, user.Email, "alice@example.com")
+	assert.Equal(t, len(user.HashedPassword), 0)
+
+	_, err = m.Get(2)
+	assert.Equal(t, err, ErrNoRecord)
+}
+
+func TestDummyHashCost(t *testing.T) {
+	// Authenticate only takes the same time for unknown email addresses if
+	// the dummy hash has the same cost as real password hashes.
+	cost, err := bcrypt.Cost(dummyHash)
+	assert.NilError(t, err)
+	assert.Equal(t, cost, 12)
+}
+
+func TestUserModelAuthenticate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantID   int
+		wantErr  error
+	}{
+		{name: "Valid", email: "alice@example.com", password: "pa$$word", wantID: 1},
+		{name: "Wrong password", email: "alice@example.com", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "Unknown email", email: "nobody@example.com", password: "pa$$word", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := UserModel{newTestDB(t)}
+
+			id, err := m.Authenticate(tt.email, tt.password)
+			assert.Equal(t, err, tt.wantErr)
+			assert.Equal(t, id, tt.wantID)
+		})
+	}
+}
+
+func TestUserModelPasswordReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+	m := UserModel{db}
+	tokens := TokenModel{db}
+
+	err := tokens.Insert("reset-hash", 1, ScopePasswordReset, time.Hour)
+	assert.NilError(t, err)
+	err = tokens.Insert("activation-hash", 1, ScopeActivation, time.Hour)
+	assert.NilError(t, err)
+
+	_, err = m.PasswordReset("wrong-hash", "n3wpa$$word")
+	assert.Equal(t, err, ErrNoRecord)
+
+	id, err := m.PasswordReset("reset-hash", "n3wpa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	id, err = m.Authenticate("alice@example.com", "n3wpa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+
+	// The token can only be used once, and tokens of other scopes are kept.
+	_, err = m.PasswordReset("reset-hash", "an0th3rpa$$word")
+	assert.Equal(t, err, ErrNoRecord)
+
+	id, err = tokens.Consume("activation-hash", ScopeActivation)
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+}
@@ -0,0 +1,212 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" as version 1-M alphanumeric data, from the worked example
+	// in the standard's tutorials.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := rsRemainder(data, rsGenerator(10))
+	assert.Equal(t, bytes.Equal(got, want), true)
+}
+
+func TestFormatBits(t *testing.T) {
+	want := []int{
+		0b101010000010010,
+		0b101000100100101,
+		0b101111001111100,
+		0b101101101001011,
+		0b100010111111001,
+		0b100000011001110,
+		0b100111110010111,
+		0b100101010100000,
+	}
+	for mask, bits := range want {
+		assert.Equal(t, formatBits(mask), bits)
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	assert.Equal(t, versionBits(7), 0b000111110010010100)
+	assert.Equal(t, versionBits(8), 0b001000010110111100)
+	assert.Equal(t, versionBits(10), 0b001010010011010011)
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		wantSize int
+	}{
+		{name: "Version 1", length: 14, wantSize: 21},
+		{name: "Version 2", length: 15, wantSize: 25},
+		{name: "Version 5", length: 84, wantSize: 37},
+		{name: "Version 7", length: 122, wantSize: 45},
+		{name: "Version 10", length: 213, wantSize: 57},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(strings.Repeat("otpauth://totp/", 15)[:tt.length])
+
+			c, err := Encode(data)
+			assert.NilError(t, err)
+			assert.Equal(t, c.Size, tt.wantSize)
+
+			checkFunctionPatterns(t, c)
+			assert.Equal(t, string(decode(t, c)), string(data))
+		})
+	}
+
+	_, err := Encode(make([]byte, 214))
+	assert.Equal(t, err, ErrTooLong)
+}
+
+func TestSVG(t *testing.T) {
+	c, err := Encode([]byte("hello"))
+	assert.NilError(t, err)
+
+	svg := c.SVG(4)
+	assert.StringContains(t, svg, `viewBox="0 0 29 29"`)
+	assert.StringContains(t, svg, `width="116"`)
+	// The top left module of the top left finder pattern.
+	assert.StringContains(t, svg, `M4,4h1v1h-1z`)
+}
+
+func checkFunctionPatterns(t *testing.T, c *Code) {
+	t.Helper()
+
+	for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if c.Dark(corner[0]+dx, corner[1]+dy) != (ring != 2) {
+					t.Fatalf("bad finder pattern at %v", corner)
+				}
+			}
+		}
+	}
+
+	for i := 8; i < c.Size-8; i++ {
+		if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("bad timing pattern at %d", i)
+		}
+	}
+
+	if !c.Dark(8, c.Size-8) {
+		t.Fatal("missing dark module")
+	}
+}
+
+// decode reads the data back out of a code, independently of the encoder's
+// own bookkeeping: it reads the format information from the modules, finds
+// the data modules from the version's layout, and checks the error
+// correction codewords.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	v := (c.Size - 17) / 4
+
+	var format int
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | b2i(c.Dark(14-i, 8))
+	}
+	format = format<<1 | b2i(c.Dark(7, 8))
+	format = format<<1 | b2i(c.Dark(8, 8))
+	format = format<<1 | b2i(c.Dark(8, 7))
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | b2i(c.Dark(8, i))
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("unrecognised format bits %015b", format)
+	}
+
+	layout := newCode(v)
+	layout.drawFunctionPatterns(v)
+
+	var bits bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.Size - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if !layout.isFunction[y][x] {
+					bits = append(bits, c.Dark(x, y) != masked(mask, x, y))
+				}
+			}
+		}
+	}
+	codewords := bits.bytes()
+
+	ver := versions[v]
+	blocks := make([][]byte, len(ver.blocks))
+	for i := 0; i < ver.blocks[len(ver.blocks)-1]; i++ {
+		for j, n := range ver.blocks {
+			if i < n {
+				blocks[j] = append(blocks[j], codewords[0])
+				codewords = codewords[1:]
+			}
+		}
+	}
+	ecs := make([][]byte, len(ver.blocks))
+	for i := 0; i < ver.ecPerBlock; i++ {
+		for j := range ecs {
+			ecs[j] = append(ecs[j], codewords[0])
+			codewords = codewords[1:]
+		}
+	}
+
+	var data bitBuffer
+	for j, block := range blocks {
+		if !bytes.Equal(rsRemainder(block, rsGenerator(ver.ecPerBlock)), ecs[j]) {
+			t.Fatalf("bad error correction in block %d", j)
+		}
+		for _, b := range block {
+			for k := 7; k >= 0; k-- {
+				data = append(data, b>>k&1 == 1)
+			}
+		}
+	}
+
+	read := func(n int) int {
+		val := 0
+		for _, bit := range data[:n] {
+			val = val<<1 | b2i(bit)
+		}
+		data = data[n:]
+		return val
+	}
+	if mode := read(4); mode != 0b0100 {
+		t.Fatalf("unexpected mode %04b", mode)
+	}
+	out := make([]byte, read(countBits(v)))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return out
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
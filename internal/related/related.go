@@ -0,0 +1,229 @@
+// Package related finds documents which are similar to each other, using the
+// overlap of their tags together with the TF-IDF cosine similarity of their
+// text. The index is held in memory and can be updated one document at a time.
+package related
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TagWeight is the share of a document's score which comes from tag overlap.
+// The rest comes from the similarity of the text.
+const TagWeight = 0.3
+
+// titleWeight is how many times each term in a title is counted, so that a
+// shared word in the titles matters more than one buried in the content.
+const titleWeight = 3
+
+// Document is the unit of indexing. IDs are chosen by the caller and must be
+// unique.
+type Document struct {
+	ID      int
+	Tags    []string
+	Title   string
+	Content string
+}
+
+type entry struct {
+	tags  map[string]bool
+	terms map[string]int
+}
+
+// Index is an in-memory index of documents. It is safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	entries map[int]*entry
+	// df counts the number of documents each term appears in.
+	df map[string]int
+}
+
+func New() *Index {
+	return &Index{
+		entries: make(map[int]*entry),
+		df:      make(map[string]int),
+	}
+}
+
+// Add indexes a document, replacing any earlier document with the same ID.
+func (ix *Index) Add(d Document) {
+	e := &entry{
+		tags:  make(map[string]bool),
+		terms: make(map[string]int),
+	}
+	for _, tag := range d.Tags {
+		if tag != "" {
+			e.tags[strings.ToLower(tag)] = true
+		}
+	}
+	for _, term := range tokenize(d.Title) {
+		e.terms[term] += titleWeight
+	}
+	for _, term := range tokenize(d.Content) {
+		e.terms[term]++
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(d.ID)
+	ix.entries[d.ID] = e
+	for term := range e.terms {
+		ix.df[term]++
+	}
+}
+
+// Remove drops a document from the index. Removing a document which isn't in
+// the index does nothing.
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id int) {
+	e, ok := ix.entries[id]
+	if !ok {
+		return
+	}
+	for term := range e.terms {
+		ix.df[term]--
+		if ix.df[term] == 0 {
+			delete(ix.df, term)
+		}
+	}
+	delete(ix.entries, id)
+}
+
+// Len returns the number of documents in the index.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.entries)
+}
+
+// Related returns the IDs of up to n documents most similar to the document
+// with the given ID, best match first. Documents with nothing in common with
+// it are never returned, and nor is the document itself.
+//
+// Inverse document frequencies are worked out at query time, so the results
+// stay correct however the index has changed since each document was added.
+func (ix *Index) Related(id, n int) []int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	target, ok := ix.entries[id]
+	if !ok || n < 1 {
+		return nil
+	}
+
+	targetVector, targetNorm := ix.vector(target)
+
+	type result struct {
+		id    int
+		score float64
+	}
+	var results []result
+
+	for otherID, other := range ix.entries {
+		if otherID == id {
+			continue
+		}
+
+		score := TagWeight * jaccard(target.tags, other.tags)
+
+		if targetNorm > 0 {
+			otherVector, otherNorm := ix.vector(other)
+			if otherNorm > 0 {
+				var dot float64
+				for term, weight := range targetVector {
+					dot += weight * otherVector[term]
+				}
+				score += (1 - TagWeight) * dot / (targetNorm * otherNorm)
+			}
+		}
+
+		if score > 0 {
+			results = append(results, result{otherID, score})
+		}
+	}
+
+	// Break ties by ID so that the order is stable between calls.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].id < results[j].id
+	})
+
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.id
+	}
+	return ids
+}
+
+// vector returns the TF-IDF weights of an entry's terms along with the
+// vector's length. Terms found in every document carry no weight.
+func (ix *Index) vector(e *entry) (map[string]float64, float64) {
+	total := float64(len(ix.entries))
+	v := make(map[string]float64, len(e.terms))
+
+	var sum float64
+	for term, tf := range e.terms {
+		idf := math.Log(total / float64(ix.df[term]))
+		w := float64(tf) * idf
+		if w > 0 {
+			v[term] = w
+			sum += w * w
+		}
+	}
+	return v, math.Sqrt(sum)
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	var shared int
+	for tag := range a {
+		if b[tag] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// stopWords are common English words which say nothing about what a document
+// is about.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+// tokenize splits text into lower-cased words, dropping single characters and
+// stop words.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	terms := fields[:0]
+	for _, f := range fields {
+		if len(f) > 1 && !stopWords[f] {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
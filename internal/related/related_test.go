@@ -0,0 +1,82 @@
+package related
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestRelated(t *testing.T) {
+	ix := New()
+	ix.Add(Document{ID: 1, Tags: []string{"go"}, Title: "HTTP server", Content: "http.ListenAndServe(addr, mux)"})
+	ix.Add(Document{ID: 2, Tags: []string{"go"}, Title: "HTTP middleware", Content: "func logRequest(next http.Handler) http.Handler"})
+	ix.Add(Document{ID: 3, Tags: []string{"python"}, Title: "Fibonacci", Content: "def fib(n): return n if n < 2 else fib(n-1) + fib(n-2)"})
+	ix.Add(Document{ID: 4, Tags: []string{"go"}, Title: "Fibonacci in Go", Content: "func fib(n int) int"})
+	ix.Add(Document{ID: 5, Tags: []string{"text"}, Title: "Haiku", Content: "An old silent pond"})
+
+	tests := []struct {
+		name string
+		id   int
+		n    int
+		want []int
+	}{
+		{
+			name: "Shared title and tag",
+			id:   1,
+			n:    1,
+			want: []int{2},
+		},
+		{
+			name: "Text outweighs tags",
+			id:   3,
+			n:    1,
+			want: []int{4},
+		},
+		{
+			name: "Tag overlap alone",
+			id:   2,
+			n:    3,
+			want: []int{1, 4},
+		},
+		{
+			name: "Nothing in common",
+			id:   5,
+			n:    3,
+			want: []int{},
+		},
+		{
+			name: "Unknown document",
+			id:   99,
+			n:    3,
+			want: []int(nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ix.Related(tt.id, tt.n)
+			assert.Equal(t, len(got), len(tt.want))
+			for i := range tt.want {
+				assert.Equal(t, got[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestAddReplacesAndRemove(t *testing.T) {
+	ix := New()
+	ix.Add(Document{ID: 1, Title: "Sorting a slice"})
+	ix.Add(Document{ID: 2, Title: "Sorting a map"})
+	ix.Add(Document{ID: 3, Title: "Reading a file"})
+
+	assert.Equal(t, len(ix.Related(1, 5)), 1)
+
+	// Re-adding a document replaces it rather than indexing it twice.
+	ix.Add(Document{ID: 2, Title: "Reading lines"})
+	assert.Equal(t, ix.Len(), 3)
+	assert.Equal(t, len(ix.Related(1, 5)), 0)
+	assert.Equal(t, ix.Related(3, 5)[0], 2)
+
+	ix.Remove(2)
+	assert.Equal(t, ix.Len(), 2)
+	assert.Equal(t, len(ix.Related(3, 5)), 0)
+}
@@ -0,0 +1,58 @@
+// Package sealed checks the payloads of snippets which are encrypted in the
+// browser. The server never sees the key, so it can't decrypt a payload, but
+// it can make sure that what it stores has the shape of ciphertext rather than
+// being plain text sent by mistake or by a broken client.
+//
+// A payload is the format version, the AES-GCM nonce and the ciphertext
+// (including its authentication tag) joined by dots, with the binary parts
+// encoded as unpadded base64url:
+//
+//	v1.<nonce>.<ciphertext>
+package sealed
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const (
+	// Version is the prefix of payloads in the current format.
+	Version = "v1"
+	// MaxSize is the largest payload accepted, in bytes.
+	MaxSize = 512 << 10
+
+	nonceSize = 12
+	tagSize   = 16
+)
+
+var (
+	ErrMalformed = errors.New("sealed: payload is not well-formed ciphertext")
+	ErrTooLarge  = errors.New("sealed: payload is too large")
+)
+
+// Validate returns nil if payload is a well-formed v1 payload.
+func Validate(payload string) error {
+	if len(payload) > MaxSize {
+		return ErrTooLarge
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 || parts[0] != Version {
+		return ErrMalformed
+	}
+
+	nonce, err := base64.RawURLEncoding.Strict().DecodeString(parts[1])
+	if err != nil || len(nonce) != nonceSize {
+		return ErrMalformed
+	}
+
+	// The ciphertext must hold at least one byte of content as well as the
+	// authentication tag.
+	ciphertext, err := base64.RawURLEncoding.Strict().DecodeString(parts[2])
+	if err != nil || len(ciphertext) <= tagSize {
+		return ErrMalformed
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package sealed
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestValidate(t *testing.T) {
+	// 12 byte nonce and 20 byte ciphertext, base64url encoded.
+	const nonce = "AAECAwQFBgcICQoL"
+	const ciphertext = "3q2-7_7u_wABAgMEBQYHCAkKCww"
+
+	tests := []struct {
+		name    string
+		payload string
+		want    error
+	}{
+		{name: "Valid", payload: "v1." + nonce + "." + ciphertext, want: nil},
+		{name: "Plain text", payload: "An old silent pond...", want: ErrMalformed},
+		{name: "Empty", payload: "", want: ErrMalformed},
+		{name: "Unknown version", payload: "v2." + nonce + "." + ciphertext, want: ErrMalformed},
+		{name: "Short nonce", payload: "v1.AAECAwQF." + ciphertext, want: ErrMalformed},
+		{name: "Only a tag", payload: "v1." + nonce + ".AAECAwQFBgcICQoLDA0ODw", want: ErrMalformed},
+		{name: "Standard base64", payload: "v1." + nonce + ".3q2+7/7u/wABAgMEBQYHCAkKCww", want: ErrMalformed},
+		{name: "Padded", payload: "v1." + nonce + "." + ciphertext + "=", want: ErrMalformed},
+		{name: "Extra part", payload: "v1." + nonce + "." + ciphertext + ".x", want: ErrMalformed},
+		{name: "Too large", payload: "v1." + nonce + "." + strings.Repeat("A", MaxSize), want: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Validate(tt.payload), tt.want)
+		})
+	}
+}
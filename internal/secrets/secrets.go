@@ -0,0 +1,289 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule is implemented by anything that can find secrets in a piece of text.
+// Find returns the byte offsets of each match as [start, end) pairs.
+type Rule interface {
+	Name() string
+	Find(content string) [][2]int
+}
+
+// Finding describes a single suspected secret found by a Scanner.
+type Finding struct {
+	Rule  string
+	Line  int
+	Start int
+	End   int
+	Match string
+}
+
+// Masked returns the first few characters of the match followed by an
+// ellipsis, so that a finding can be displayed without repeating the secret.
+// It counts characters rather than bytes, so that the result stays valid
+// UTF-8 whatever a rule matches.
+func (f Finding) Masked() string {
+	const visible = 4
+	runes := []rune(f.Match)
+	if len(runes) <= visible {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:visible]) + "…"
+}
+
+// RegexRule matches secrets with a regular expression. If the expression
+// contains a capture group, only the first group is treated as the secret,
+// which allows rules to anchor on surrounding context such as a key name.
+type RegexRule struct {
+	RuleName string
+	Pattern  *regexp.Regexp
+}
+
+func (r *RegexRule) Name() string {
+	return r.RuleName
+}
+
+func (r *RegexRule) Find(content string) [][2]int {
+	var matches [][2]int
+	for _, m := range r.Pattern.FindAllStringSubmatchIndex(content, -1) {
+		if len(m) >= 4 && m[2] >= 0 {
+			matches = append(matches, [2]int{m[2], m[3]})
+		} else {
+			matches = append(matches, [2]int{m[0], m[1]})
+		}
+	}
+	return matches
+}
+
+// EntropyRule flags tokens of at least MinLength characters drawn from the
+// base64 and hex alphabets whose Shannon entropy, in bits per character, is at
+// least Threshold. Tokens containing slashes are usually paths, such as import
+// paths and URLs, so each part between the slashes is checked on its own
+// unless the token has base64's "+" or "=" padding in it.
+type EntropyRule struct {
+	MinLength int
+	Threshold float64
+}
+
+var tokenRX = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+func (r *EntropyRule) Name() string {
+	return "High-entropy string"
+}
+
+func (r *EntropyRule) Find(content string) [][2]int {
+	var matches [][2]int
+	for _, m := range tokenRX.FindAllStringIndex(content, -1) {
+		token := content[m[0]:m[1]]
+		if !strings.Contains(token, "/") || strings.ContainsAny(token, "+=") {
+			if r.isSecret(token) {
+				matches = append(matches, [2]int{m[0], m[1]})
+			}
+			continue
+		}
+
+		start := m[0]
+		for _, part := range strings.Split(token, "/") {
+			if r.isSecret(part) {
+				matches = append(matches, [2]int{start, start + len(part)})
+			}
+			start += len(part) + 1
+		}
+	}
+	return matches
+}
+
+func (r *EntropyRule) isSecret(token string) bool {
+	if len(token) < r.MinLength {
+		return false
+	}
+	// Identifiers, paths and prose are mostly letters, with the odd digit or
+	// separator; real keys mix letters and digits, so require both.
+	if !strings.ContainsAny(token, "0123456789") || !strings.ContainsFunc(token, isLetter) {
+		return false
+	}
+	return Entropy(token) >= r.Threshold
+}
+
+func isLetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// Entropy returns the Shannon entropy of s in bits per character.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	var entropy float64
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// DefaultRules returns the built-in rules for AWS keys, GitHub tokens, private
+// key PEM blocks, JSON Web Tokens and high-entropy strings.
+func DefaultRules() []Rule {
+	return []Rule{
+		&RegexRule{
+			RuleName: "Private key",
+			Pattern:  regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`),
+		},
+		&RegexRule{
+			RuleName: "AWS access key ID",
+			Pattern:  regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b`),
+		},
+		&RegexRule{
+			RuleName: "AWS secret access key",
+			Pattern:  regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?[=:]\s*["']?([A-Za-z0-9/+]{40})\b`),
+		},
+		&RegexRule{
+			RuleName: "GitHub token",
+			Pattern:  regexp.MustCompile(`\b(?:(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+		},
+		&RegexRule{
+			RuleName: "JSON Web Token",
+			Pattern:  regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`),
+		},
+		&EntropyRule{
+			MinLength: 24,
+			Threshold: 4.0,
+		},
+	}
+}
+
+// fileConfig is the JSON structure read by LoadRules.
+type fileConfig struct {
+	IncludeDefaults bool `json:"include_defaults"`
+	Rules           []struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+	} `json:"rules"`
+	Entropy *struct {
+		MinLength int     `json:"min_length"`
+		Threshold float64 `json:"threshold"`
+	} `json:"entropy"`
+}
+
+// LoadRules reads scanner rules from a JSON file of the form:
+//
+//	{
+//	  "include_defaults": false,
+//	  "rules": [{"name": "Slack token", "pattern": "xox[baprs]-[A-Za-z0-9-]+"}],
+//	  "entropy": {"min_length": 24, "threshold": 4.0}
+//	}
+//
+// If include_defaults is true the built-in rules are returned as well, with
+// the entropy settings from the file (if any) replacing the default ones.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg fileConfig
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: parsing %s: %w", path, err)
+	}
+
+	var rules []Rule
+	if cfg.IncludeDefaults {
+		for _, rule := range DefaultRules() {
+			if _, ok := rule.(*EntropyRule); ok && cfg.Entropy != nil {
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, r := range cfg.Rules {
+		rx, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: rule %q: %w", r.Name, err)
+		}
+		rules = append(rules, &RegexRule{RuleName: r.Name, Pattern: rx})
+	}
+
+	if cfg.Entropy != nil {
+		rules = append(rules, &EntropyRule{MinLength: cfg.Entropy.MinLength, Threshold: cfg.Entropy.Threshold})
+	}
+
+	return rules, nil
+}
+
+// Scanner runs a set of rules over snippet content.
+type Scanner struct {
+	rules []Rule
+}
+
+// New returns a Scanner which uses the given rules, in order. When matches
+// from different rules overlap, the one from the earlier rule wins.
+func New(rules ...Rule) *Scanner {
+	return &Scanner{rules: rules}
+}
+
+// Scan returns the findings in content, ordered by their position.
+func (s *Scanner) Scan(content string) []Finding {
+	var findings []Finding
+
+	for _, rule := range s.rules {
+		for _, m := range rule.Find(content) {
+			if overlaps(findings, m) {
+				continue
+			}
+			findings = append(findings, Finding{
+				Rule:  rule.Name(),
+				Line:  strings.Count(content[:m[0]], "\n") + 1,
+				Start: m[0],
+				End:   m[1],
+				Match: content[m[0]:m[1]],
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].Start < findings[j].Start
+	})
+
+	return findings
+}
+
+func overlaps(findings []Finding, m [2]int) bool {
+	for _, f := range findings {
+		if m[0] < f.End && f.Start < m[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns content with each finding replaced by a placeholder naming
+// the rule that matched. The findings must come from scanning the same
+// content, as returned by Scan.
+func Redact(content string, findings []Finding) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(content[last:f.Start])
+		b.WriteString("[REDACTED " + strings.ToUpper(f.Rule) + "]")
+		last = f.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
@@ -0,0 +1,50 @@
+// Package spdx validates SPDX license identifiers against a list embedded in
+// the binary.
+package spdx
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+)
+
+// License is a single entry in the SPDX license list.
+type License struct {
+	ID   string
+	Name string
+}
+
+//go:embed licenses.txt
+var licensesTxt string
+
+// Licenses lists the known licenses, in the order of the embedded list.
+var Licenses = parse(licensesTxt)
+
+func parse(txt string) []License {
+	var licenses []License
+
+	s := bufio.NewScanner(strings.NewReader(txt))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, name, _ := strings.Cut(line, "\t")
+		licenses = append(licenses, License{ID: id, Name: strings.TrimSpace(name)})
+	}
+
+	return licenses
+}
+
+// Lookup finds the license with the given identifier. As in the SPDX
+// specification, identifiers are matched case-insensitively, and the license
+// returned has the canonical spelling of the identifier.
+func Lookup(id string) (License, bool) {
+	id = strings.TrimSpace(id)
+	for _, l := range Licenses {
+		if strings.EqualFold(l.ID, id) {
+			return l, true
+		}
+	}
+	return License{}, false
+}
@@ -0,0 +1,42 @@
+package spdx
+
+import (
+	"testing"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		wantID string
+		wantOK bool
+	}{
+		{name: "Exact", id: "MIT", wantID: "MIT", wantOK: true},
+		{name: "Different case", id: "apache-2.0", wantID: "Apache-2.0", wantOK: true},
+		{name: "Surrounding space", id: " GPL-3.0-or-later ", wantID: "GPL-3.0-or-later", wantOK: true},
+		{name: "Deprecated identifier", id: "GPL-3.0", wantOK: false},
+		{name: "Unknown", id: "Proprietary", wantOK: false},
+		{name: "Empty", id: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, ok := Lookup(tt.id)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, l.ID, tt.wantID)
+		})
+	}
+}
+
+func TestLicensesParsed(t *testing.T) {
+	l, ok := Lookup("BSD-3-Clause")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, l.Name, `BSD 3-Clause "New" or "Revised" License`)
+
+	for _, l := range Licenses {
+		if l.ID == "" || l.Name == "" || l.ID[0] == '#' {
+			t.Errorf("bad entry in license list: %+v", l)
+		}
+	}
+}
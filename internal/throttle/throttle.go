@@ -0,0 +1,231 @@
+// Package throttle slows down repeated failures, such as wrong passwords,
+// with exponential backoff and a temporary lockout. Failures are counted per
+// key, for example per account or per IP address, in a Store which can be
+// kept in memory or shared between processes in a database.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Record is the failures counted for a key: how many there have been, and
+// when the last one was. A record returned by Reserve also has the time of
+// the failure before the reserved one in Prior, so that Release can put it
+// back.
+type Record struct {
+	Failures int
+	Last     time.Time
+	Prior    time.Time
+}
+
+// Store keeps a Record for each key.
+type Store interface {
+	// Get returns the record for key, which is the zero Record if there
+	// have been no failures.
+	Get(key string) (Record, error)
+	// Fail adds a failure at now to the record for key and returns the
+	// updated record. If the last failure was more than window before now,
+	// the count starts again from one.
+	Fail(key string, now time.Time, window time.Duration) (Record, error)
+	// Reserve adds a failure at now to the record for key, as Fail does,
+	// unless wait returns more than zero for the current record, in which
+	// case the record is left alone and the wait returned. The check and
+	// the update are atomic, so concurrent callers can't both pass the
+	// check on the same record.
+	Reserve(key string, now time.Time, window time.Duration, wait func(Record) time.Duration) (Record, time.Duration, error)
+	// Release takes back the failure added by the Reserve which returned
+	// rec. The time of the last failure goes back to rec.Prior, unless
+	// there has been another failure since.
+	Release(key string, rec Record) error
+	// Reset forgets the failures for key.
+	Reset(key string) error
+}
+
+// Policy says how long to wait after a number of failures. The first Free
+// failures cost nothing; after that the wait starts at BaseDelay and doubles
+// with each failure, up to MaxDelay. At LockoutAfter failures the key is
+// locked for LockoutFor. Failures are forgotten after Window passes without
+// another one.
+type Policy struct {
+	Free         int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockoutAfter int
+	LockoutFor   time.Duration
+	Window       time.Duration
+}
+
+// Delay returns how long to wait after the last of the given number of
+// failures.
+func (p Policy) Delay(failures int) time.Duration {
+	switch {
+	case failures >= p.LockoutAfter:
+		return p.LockoutFor
+	case failures < p.Free:
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Free; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Throttle applies a Policy to the failures kept in a Store.
+type Throttle struct {
+	Store  Store
+	Policy Policy
+}
+
+// Wait returns how long from now until key may be tried again, which is 0
+// if it may be tried straight away.
+func (t *Throttle) Wait(key string, now time.Time) (time.Duration, error) {
+	rec, err := t.Store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return t.wait(rec, now), nil
+}
+
+func (t *Throttle) wait(rec Record, now time.Time) time.Duration {
+	if rec.Failures == 0 || now.Sub(rec.Last) > t.Policy.Window {
+		return 0
+	}
+	return max(rec.Last.Add(t.Policy.Delay(rec.Failures)).Sub(now), 0)
+}
+
+// Attempt counts an attempt at key as a failure before it is made, so that
+// concurrent attempts can't all get past the wait before any of them has
+// failed. If key may not be tried yet, nothing is counted and the wait is
+// returned. An attempt which turns out not to be a failure should be given
+// back with Release, or followed by Reset.
+func (t *Throttle) Attempt(key string, now time.Time) (Record, time.Duration, error) {
+	return t.Store.Reserve(key, now, t.Policy.Window, func(rec Record) time.Duration {
+		return t.wait(rec, now)
+	})
+}
+
+// Release gives back an attempt counted by Attempt which didn't fail, given
+// the record Attempt returned for it.
+func (t *Throttle) Release(key string, rec Record) error {
+	return t.Store.Release(key, rec)
+}
+
+// Locks reports whether the failure which brought a key to rec is the one
+// which locked it.
+func (t *Throttle) Locks(rec Record) bool {
+	return rec.Failures == t.Policy.LockoutAfter
+}
+
+// Fail records a failure for key. It reports whether this failure locked
+// the key, so that the lockout can be acted on once rather than for every
+// failure after it.
+func (t *Throttle) Fail(key string, now time.Time) (Record, bool, error) {
+	rec, err := t.Store.Fail(key, now, t.Policy.Window)
+	if err != nil {
+		return Record{}, false, err
+	}
+	return rec, t.Locks(rec), nil
+}
+
+// Reset forgets the failures for key, after a success.
+func (t *Throttle) Reset(key string) error {
+	return t.Store.Reset(key)
+}
+
+// MemoryStore is a Store which keeps records in memory, so they are per
+// process and lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records[key], nil
+}
+
+func (s *MemoryStore) Fail(key string, now time.Time, window time.Duration) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now, window)
+
+	rec := s.records[key]
+	if now.Sub(rec.Last) > window {
+		rec.Failures = 0
+	}
+	rec.Failures++
+	rec.Last = now
+	s.records[key] = rec
+
+	return rec, nil
+}
+
+func (s *MemoryStore) Reserve(key string, now time.Time, window time.Duration, wait func(Record) time.Duration) (Record, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now, window)
+
+	rec := s.records[key]
+	prior := rec.Last
+	if now.Sub(rec.Last) > window {
+		rec = Record{}
+	}
+	if d := wait(rec); d > 0 {
+		return rec, d, nil
+	}
+	rec.Failures++
+	rec.Last = now
+	s.records[key] = rec
+
+	rec.Prior = prior
+	return rec, 0, nil
+}
+
+func (s *MemoryStore) Release(key string, reserved Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[key]
+	if ok && rec.Failures > 0 {
+		rec.Failures--
+		if rec.Last.Equal(reserved.Last) && !reserved.Prior.IsZero() {
+			rec.Last = reserved.Prior
+		}
+		s.records[key] = rec
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+// sweep forgets records whose window has ended, at most once per window, so
+// that the map doesn't grow without bound.
+func (s *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.swept) < window {
+		return
+	}
+	for key, rec := range s.records {
+		if now.Sub(rec.Last) > window {
+			delete(s.records, key)
+		}
+	}
+	s.swept = now
+}
@@ -0,0 +1,139 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+var policy = Policy{
+	Free:         3,
+	BaseDelay:    time.Second,
+	MaxDelay:     10 * time.Second,
+	LockoutAfter: 8,
+	LockoutFor:   15 * time.Minute,
+	Window:       time.Hour,
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Second},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 5, want: 4 * time.Second},
+		{failures: 6, want: 8 * time.Second},
+		{failures: 7, want: 10 * time.Second},
+		{failures: 8, want: 15 * time.Minute},
+		{failures: 100, want: 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, policy.Delay(tt.failures), tt.want)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	th := &Throttle{Store: NewMemoryStore(), Policy: policy}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		rec, locked, err := th.Fail("a", now)
+		assert.NilError(t, err)
+		assert.Equal(t, rec.Failures, i)
+		assert.Equal(t, locked, false)
+	}
+
+	wait, err := th.Wait("a", now)
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Second)
+
+	// Other keys aren't affected.
+	wait, err = th.Wait("b", now)
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Duration(0))
+
+	for i := 4; i <= 8; i++ {
+		_, locked, err := th.Fail("a", now)
+		assert.NilError(t, err)
+		assert.Equal(t, locked, i == 8)
+	}
+
+	wait, err = th.Wait("a", now.Add(time.Minute))
+	assert.NilError(t, err)
+	assert.Equal(t, wait, 14*time.Minute)
+
+	// Failures are forgotten after the window, and on reset.
+	wait, err = th.Wait("a", now.Add(2*time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Duration(0))
+
+	rec, _, err := th.Fail("a", now.Add(2*time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 1)
+
+	assert.NilError(t, th.Reset("a"))
+	wait, err = th.Wait("a", now.Add(2*time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, wait, time.Duration(0))
+}
+
+func TestThrottleAttempt(t *testing.T) {
+	th := &Throttle{Store: NewMemoryStore(), Policy: policy}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Attempts are counted up front, until the key has to wait.
+	for i := 1; i <= 3; i++ {
+		rec, wait, err := th.Attempt("a", now)
+		assert.NilError(t, err)
+		assert.Equal(t, rec.Failures, i)
+		assert.Equal(t, wait, time.Duration(0))
+	}
+
+	rec, wait, err := th.Attempt("a", now)
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 3)
+	assert.Equal(t, wait, time.Second)
+
+	// An attempt which didn't fail is given back, without moving the time
+	// of the last failure.
+	rec, _, err = th.Attempt("a", now.Add(time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 4)
+	assert.NilError(t, th.Release("a", rec))
+
+	rec, err = th.Store.Get("a")
+	assert.NilError(t, err)
+	assert.Equal(t, rec.Failures, 3)
+	assert.Equal(t, rec.Last, now)
+}
+
+func TestThrottleAttemptConcurrent(t *testing.T) {
+	th := &Throttle{Store: NewMemoryStore(), Policy: Policy{LockoutAfter: 3, LockoutFor: time.Hour, Window: time.Hour}}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, wait, err := th.Attempt("a", now)
+			assert.NilError(t, err)
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, allowed, 3)
+}
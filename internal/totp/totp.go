@@ -0,0 +1,109 @@
+// Package totp implements time-based one-time passwords as described in
+// RFC 6238, with the defaults authenticator apps expect: HMAC-SHA1, six
+// digits and a 30 second period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	// SecretSize is the length of generated secrets in bytes, the size of an
+	// SHA1 output as RFC 4226 recommends.
+	SecretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random secret.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeSecret returns the secret in base32 without padding, the form users
+// type into authenticator apps.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth URI for a secret, for showing as a QR code. The
+// label is issuer:account, and the issuer is repeated as a parameter since
+// apps differ in which they read.
+func URI(issuer, account string, secret []byte) string {
+	v := url.Values{}
+	v.Set("secret", EncodeSecret(secret))
+	v.Set("issuer", issuer)
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// Step returns the number of periods between the Unix epoch and t.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for the step containing t.
+func Code(secret []byte, t time.Time) string {
+	return hotp(secret, uint64(Step(t)), Digits)
+}
+
+// Validate checks a code against the steps within skew periods either side
+// of t, to allow for clocks which differ and for time spent typing. It
+// returns the step which matched, which callers should record and refuse to
+// accept again so that a code can't be replayed.
+func Validate(secret []byte, code string, t time.Time, skew int) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	// Check every step rather than stopping at a match, so the time taken
+	// doesn't reveal which one matched.
+	var matched int64
+	ok := false
+	step := Step(t)
+	for i := -int64(skew); i <= int64(skew); i++ {
+		want := hotp(secret, uint64(step+i), Digits)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 && !ok {
+			matched, ok = step+i, true
+		}
+	}
+	return matched, ok
+}
+
+// hotp computes the HOTP value of RFC 4226 for a counter.
+func hotp(secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FerMusicComposer/lets-go-snippetbox.git/internal/assert"
+)
+
+// The SHA1 test vectors from appendix B of RFC 6238.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			step := Step(time.Unix(tt.unix, 0))
+			assert.Equal(t, hotp(rfcSecret, uint64(step), 8), tt.want)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code := Code(rfcSecret, now)
+	assert.Equal(t, code, "050471")
+
+	tests := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "Current", code: code, at: now, wantStep: Step(now), wantOK: true},
+		{name: "Previous step", code: code, at: now.Add(Period), wantStep: Step(now), wantOK: true},
+		{name: "Next step", code: code, at: now.Add(-Period), wantStep: Step(now), wantOK: true},
+		{name: "Too old", code: code, at: now.Add(2 * Period)},
+		{name: "Surrounding space", code: " " + code + " ", at: now, wantStep: Step(now), wantOK: true},
+		{name: "Wrong", code: "123456", at: now},
+		{name: "Wrong length", code: "50471", at: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, tt.at, 1)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, step, tt.wantStep)
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Snippetbox", "alice@example.com", rfcSecret)
+	assert.Equal(t, uri, "otpauth://totp/Snippetbox:alice@example.com?issuer=Snippetbox&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+}
@@ -1,8 +1,10 @@
 package validator
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -11,6 +13,10 @@ type Validator struct {
 	FieldErrors    map[string]string
 }
 
+// SlugRX matches URL slugs made of lowercase letters and digits separated by
+// single hyphens, such as "onboarding-pack-2".
+var SlugRX = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 // Valid checks if the Validator object is valid.
@@ -106,3 +112,15 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// WebURL returns true if a value is an absolute http or https URL.
+func WebURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// SingleLine returns true if a value contains no newlines or other control
+// characters.
+func SingleLine(value string) bool {
+	return strings.IndexFunc(value, unicode.IsControl) == -1
+}
@@ -5,4 +5,37 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Highlight the lines named by a "#L10" or "#L10-L20" fragment. Single lines
+// can be targeted by the browser on its own, but ranges have no matching id,
+// so scroll to the first line of the range as well.
+function highlightLines() {
+	var match = /^#L(\d+)(?:-L(\d+))?$/.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+	var start = parseInt(match[1], 10);
+	var end = match[2] ? parseInt(match[2], 10) : start;
+	var lines = document.querySelectorAll("pre.lines .line");
+	for (var i = 0; i < lines.length; i++) {
+		var n = i + 1;
+		lines[i].classList.toggle("highlight", n >= start && n <= end);
+	}
+	var first = document.getElementById("L" + start);
+	if (first) {
+		first.scrollIntoView();
+	}
+}
+
+highlightLines();
+window.addEventListener("hashchange", highlightLines);
+
+// Fill in the browser's timezone for inputs which ask for it, unless the user
+// has already changed the value from the UTC default.
+var timezoneInputs = document.querySelectorAll("input[data-detect-timezone]");
+for (var i = 0; i < timezoneInputs.length; i++) {
+	if (timezoneInputs[i].value === "UTC" && window.Intl) {
+		timezoneInputs[i].value = Intl.DateTimeFormat().resolvedOptions().timeZone;
+	}
+}
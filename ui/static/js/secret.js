@@ -0,0 +1,64 @@
+// Client-side encryption for secret snippets. Content is encrypted with a
+// random AES-GCM key, and the key is kept in the URL fragment, which browsers
+// never send to the server. The payload format, "v1.<nonce>.<ciphertext>" in
+// unpadded base64url, is checked on the server by the sealed package.
+
+function toBase64URL(bytes) {
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(text) {
+	var binary = atob(text.replace(/-/g, "+").replace(/_/g, "/"));
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes;
+}
+
+var secretForm = document.getElementById("secret-form");
+if (secretForm) {
+	secretForm.addEventListener("submit", function (event) {
+		event.preventDefault();
+
+		var plaintext = document.getElementById("secret-plaintext").value;
+		var nonce = crypto.getRandomValues(new Uint8Array(12));
+		var key;
+
+		crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt"]).then(function (k) {
+			key = k;
+			return crypto.subtle.encrypt({name: "AES-GCM", iv: nonce}, key, new TextEncoder().encode(plaintext));
+		}).then(function (ciphertext) {
+			secretForm.elements["payload"].value = "v1." + toBase64URL(nonce) + "." + toBase64URL(new Uint8Array(ciphertext));
+			return crypto.subtle.exportKey("raw", key);
+		}).then(function (raw) {
+			// The server redirects to the new snippet without a fragment, so
+			// the browser keeps this one, and the key, across the redirect.
+			secretForm.action = "/secret/create#" + toBase64URL(new Uint8Array(raw));
+			secretForm.submit();
+		});
+	});
+}
+
+var secretContent = document.getElementById("secret-content");
+if (secretContent) {
+	var parts = secretContent.dataset.payload.split(".");
+	var fail = function () {
+		secretContent.textContent = "This snippet can't be decrypted. Check that you have the full link, including the part after the #.";
+	};
+
+	try {
+		var rawKey = fromBase64URL(window.location.hash.slice(1));
+		crypto.subtle.importKey("raw", rawKey, {name: "AES-GCM"}, false, ["decrypt"]).then(function (key) {
+			return crypto.subtle.decrypt({name: "AES-GCM", iv: fromBase64URL(parts[1])}, key, fromBase64URL(parts[2]));
+		}).then(function (plaintext) {
+			secretContent.textContent = new TextDecoder().decode(plaintext);
+		}, fail);
+	} catch (e) {
+		fail();
+	}
+}